@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"sync/atomic"
+	"time"
 
 	"github.com/golang/glog"
 )
@@ -44,30 +45,76 @@ func (lc *LogCounter) String() string {
 	return strconv.FormatUint(atomic.LoadUint64(&lc.value), 10)
 }
 
+// Timer defines an interface for recording durations.
+type Timer interface {
+	Observe(time.Duration)
+}
+
+// LogTimer implements the Timer interface, keeping the count, total and
+// maximum of the recorded durations. It's safe for concurrent use.
+type LogTimer struct {
+	count, total, max int64
+}
+
+// Observe records the given duration.
+func (lt *LogTimer) Observe(d time.Duration) {
+	atomic.AddInt64(&lt.count, 1)
+	atomic.AddInt64(&lt.total, int64(d))
+	for {
+		max := atomic.LoadInt64(&lt.max)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&lt.max, max, int64(d)) {
+			return
+		}
+	}
+}
+
+// String returns a string representation of the timer.
+func (lt *LogTimer) String() string {
+	count := atomic.LoadInt64(&lt.count)
+	var avg time.Duration
+	if count > 0 {
+		avg = time.Duration(atomic.LoadInt64(&lt.total) / count)
+	}
+	max := time.Duration(atomic.LoadInt64(&lt.max))
+	return "count=" + strconv.FormatInt(count, 10) + " avg=" + avg.String() + " max=" + max.String()
+}
+
 // LogOut holds metrics captured in an instrumented runtime.
 type LogOut struct {
-	MesosRequests     Counter
-	MesosSuccess      Counter
-	MesosNXDomain     Counter
-	MesosFailed       Counter
-	NonMesosRequests  Counter
-	NonMesosSuccess   Counter
-	NonMesosNXDomain  Counter
-	NonMesosFailed    Counter
-	NonMesosForwarded Counter
+	MesosRequests      Counter
+	MesosSuccess       Counter
+	MesosNXDomain      Counter
+	MesosFailed        Counter
+	NonMesosRequests   Counter
+	NonMesosSuccess    Counter
+	NonMesosNXDomain   Counter
+	NonMesosFailed     Counter
+	NonMesosForwarded  Counter
+	RecordsAdded       Counter
+	RecordsRemoved     Counter
+	HostLookups        Counter
+	HostLookupFailures Counter
+	HostLookupLatency  Timer
+	NameCollisions     Counter
 }
 
 // CurLog is the default package level LogOut.
 var CurLog = LogOut{
-	MesosRequests:     &LogCounter{},
-	MesosSuccess:      &LogCounter{},
-	MesosNXDomain:     &LogCounter{},
-	MesosFailed:       &LogCounter{},
-	NonMesosRequests:  &LogCounter{},
-	NonMesosSuccess:   &LogCounter{},
-	NonMesosNXDomain:  &LogCounter{},
-	NonMesosFailed:    &LogCounter{},
-	NonMesosForwarded: &LogCounter{},
+	MesosRequests:      &LogCounter{},
+	MesosSuccess:       &LogCounter{},
+	MesosNXDomain:      &LogCounter{},
+	MesosFailed:        &LogCounter{},
+	NonMesosRequests:   &LogCounter{},
+	NonMesosSuccess:    &LogCounter{},
+	NonMesosNXDomain:   &LogCounter{},
+	NonMesosFailed:     &LogCounter{},
+	NonMesosForwarded:  &LogCounter{},
+	RecordsAdded:       &LogCounter{},
+	RecordsRemoved:     &LogCounter{},
+	HostLookups:        &LogCounter{},
+	HostLookupFailures: &LogCounter{},
+	HostLookupLatency:  &LogTimer{},
+	NameCollisions:     &LogCounter{},
 }
 
 // PrintCurLog prints out the current LogOut and then resets
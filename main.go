@@ -11,6 +11,7 @@ import (
 	"github.com/mesosphere/mesos-dns/detect"
 	"github.com/mesosphere/mesos-dns/logging"
 	"github.com/mesosphere/mesos-dns/records"
+	"github.com/mesosphere/mesos-dns/records/state"
 	"github.com/mesosphere/mesos-dns/resolver"
 	"github.com/mesosphere/mesos-dns/util"
 )
@@ -52,6 +53,15 @@ func main() {
 		go func() { errch <- <-res.LaunchHTTP() }()
 	}
 
+	// stream state updates, if configured, instead of polling them
+	var stream *records.StateStream
+	var states <-chan state.State
+	if config.StateUpdates == records.StreamStateUpdates {
+		stream = records.NewStateStream(config, res.Transport())
+		states = stream.States()
+		go stream.Run()
+	}
+
 	changed := detectMasters(config.Zk, config.Masters)
 	reload := time.NewTicker(time.Second * time.Duration(config.RefreshSeconds))
 	zkTimeout := time.Second * time.Duration(config.ZkDetectionTimeout)
@@ -66,7 +76,11 @@ func main() {
 	for {
 		select {
 		case <-reload.C:
-			res.Reload()
+			if stream != nil {
+				stream.Resync()
+			} else {
+				res.Reload()
+			}
 		case masters := <-changed:
 			if len(masters) == 0 || masters[0] == "" { // no leader
 				timeout.Reset(zkTimeout)
@@ -75,7 +89,13 @@ func main() {
 			}
 			logging.VeryVerbose.Printf("new masters detected: %v", masters)
 			res.SetMasters(masters)
-			res.Reload()
+			if stream != nil {
+				stream.SetMasters(masters)
+			} else {
+				res.Reload()
+			}
+		case sj := <-states:
+			res.Update(sj)
 		case err := <-errch:
 			logging.Error.Fatal(err)
 		}
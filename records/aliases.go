@@ -0,0 +1,158 @@
+package records
+
+import (
+	"strings"
+
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/mesosphere/mesos-dns/records/labels"
+	"github.com/mesosphere/mesos-dns/records/state"
+	"github.com/miekg/dns"
+)
+
+// AliasesLabel is the key of the task label holding the comma separated list
+// of the task's aliases, fully qualified names within the domain, e.g.
+// "api.mesos,api.prod.mesos".
+const AliasesLabel = "DNS_ALIASES"
+
+// taskAliases returns the aliases of the given task set by its AliasesLabel
+// label, relative to the given domain and mangled with the given label func.
+// Aliases outside of the domain are rejected.
+func taskAliases(task *state.Task, domain string, spec labels.Func) []string {
+	v, ok := task.LabelValue(AliasesLabel)
+	if !ok {
+		return nil
+	}
+	suffix := "." + strings.ToLower(domain)
+	var aliases []string
+	for _, alias := range strings.Split(v, ",") {
+		alias = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(alias)), ".")
+		if alias == "" {
+			continue
+		}
+		if !strings.HasSuffix(alias, suffix) {
+			logging.Verbose.Printf("rejected alias %q of task %q: not within domain %q", alias, task.ID, domain)
+			continue
+		}
+		name := labels.DomainFrag(strings.TrimSuffix(alias, suffix), labels.Sep, spec)
+		if name == "" {
+			logging.Verbose.Printf("rejected alias %q of task %q: invalid name", alias, task.ID)
+			continue
+		}
+		aliases = append(aliases, name)
+	}
+	return aliases
+}
+
+// insertAliases publishes the given aliases, relative to the given tail, of
+// the task of the given origin named taskName within framework fname. Each
+// alias gets the A and AAAA records the task has under the given target, its
+// first name generated by the NameTemplates, and the SRV records it has
+// under its name (e.g. api.domain for web.marathon.domain and
+// _api._tcp.domain for _web._tcp.marathon.domain), either as CNAME records
+// or as copies of the records, depending on the AliasMode.
+func (rg *RecordGenerator) insertAliases(aliases []string, target, taskName, fname, tail string, origin Origin) {
+	for _, alias := range aliases {
+		first, rest := alias, ""
+		if i := strings.Index(alias, "."); i >= 0 {
+			first, rest = alias[:i], alias[i:]
+		}
+		names := []struct {
+			alias, target string
+			types         []uint16
+		}{
+			{alias, target, []uint16{dns.TypeA, dns.TypeAAAA}},
+			{"_" + first + "._tcp" + rest, "_" + taskName + "._tcp." + fname, []uint16{dns.TypeSRV}},
+			{"_" + first + "._udp" + rest, "_" + taskName + "._udp." + fname, []uint16{dns.TypeSRV}},
+		}
+		if rg.reservedAlias(alias, tail) || rg.reservedName(names[1].alias+tail) || rg.reservedName(names[2].alias+tail) {
+			logging.Verbose.Printf("rejected alias %q of task %q: reserved name", alias+tail, origin.TaskID)
+			continue
+		}
+		for _, n := range names {
+			var rrs []RR
+			for _, rtype := range n.types {
+				for _, rr := range rg.Records.Get(n.target+tail, rtype) {
+					if rr.Origin == origin {
+						rrs = append(rrs, rr)
+					}
+				}
+			}
+			if len(rrs) == 0 {
+				continue
+			}
+			if rg.config.AliasMode == CNAMEAliasMode {
+				rg.insertRR(RR{Name: n.alias + tail, Type: dns.TypeCNAME, Target: n.target + tail, Class: TaskClass, Origin: origin})
+				continue
+			}
+			for _, rr := range rrs {
+				rr.Name = n.alias + tail
+				rg.insertRR(rr)
+			}
+		}
+	}
+}
+
+// reservedAlias reports whether the given alias, relative to the given tail,
+// names records the generator owns: the leader, masters, agents and name
+// server names, the agents and frameworks zones, or any reservedName.
+func (rg *RecordGenerator) reservedAlias(alias, tail string) bool {
+	switch {
+	case alias == "leader", alias == "master", alias == "slave",
+		strings.HasPrefix(alias, "master") && strings.Trim(alias[len("master"):], "0123456789") == "":
+		return true
+	}
+	for _, zone := range []string{"agents", "frameworks"} {
+		if alias == zone || strings.HasSuffix(alias, "."+zone) {
+			return true
+		}
+	}
+	if strings.TrimSuffix(rg.config.SOAMname, ".")+"." == alias+tail {
+		return true
+	}
+	return rg.reservedName(alias + tail)
+}
+
+// reservedName reports whether the given name has records other than the
+// tasks', e.g. of masters, agents, frameworks or static files, or is within
+// a zone delegated by static files.
+func (rg *RecordGenerator) reservedName(name string) bool {
+	if _, ok := rg.owned[name]; ok {
+		return true
+	}
+	for i := strings.Index(name, "."); i >= 0 && i < len(name)-1; i = strings.Index(name, ".") {
+		name = name[i+1:]
+		for _, rr := range rg.Records.Get(name, dns.TypeNS) {
+			if rr.Class == StaticClass {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dropShadowingAliases drops the CNAME records of names which have other
+// records too, e.g. aliases named after another task, since a CNAME record
+// can't coexist with other records of its name. Names with several CNAME
+// records, claimed by several apps, keep the first one only.
+func (rg *RecordGenerator) dropShadowingAliases() {
+	if rg.Records.Count(dns.TypeCNAME) == 0 {
+		return
+	}
+	rs := NewRecordSet()
+	for _, rr := range rg.Records.All() {
+		if rr.Type != dns.TypeCNAME {
+			rs.insert(rr)
+			continue
+		}
+		cnames := rg.Records.Get(rr.Name, dns.TypeCNAME)
+		switch {
+		case rg.Records.names[rr.Name] > len(cnames):
+			logging.Verbose.Printf("dropped alias %s: name has other records", rr)
+		case rr.Target != cnames[0].Target:
+			logging.Verbose.Printf("dropped alias %s: name is an alias of %s", rr, cnames[0].Target)
+		default:
+			rs.insert(rr)
+		}
+	}
+	rg.Records = rs
+}
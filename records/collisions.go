@@ -0,0 +1,146 @@
+package records
+
+import (
+	"sort"
+
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/miekg/dns"
+)
+
+// Collision policies selecting the records of names claimed by several apps.
+const (
+	// MergePolicy publishes the records of all the apps.
+	MergePolicy = "merge"
+	// FirstWinsPolicy publishes the records of the first app only.
+	FirstWinsPolicy = "first-wins"
+	// DropPolicy publishes no records of any of the apps.
+	DropPolicy = "drop"
+)
+
+// App identifies the app records are generated for: a framework, or a task
+// name (its DiscoveryInfo name if defined) within a framework, whose
+// instances legitimately share names.
+type App struct {
+	FrameworkID string `json:"framework_id,omitempty"`
+	Framework   string `json:"framework"`
+	Task        string `json:"task,omitempty"`
+}
+
+// Collision is a name claimed by several apps, e.g. because their names are
+// mangled to the same DNS labels.
+type Collision struct {
+	Name string `json:"name"`
+	// Apps lists the apps claiming the name, in the order they did.
+	Apps []App `json:"apps"`
+}
+
+// claims tracks the apps which claimed each name.
+type claims struct {
+	// apps holds the App of each record origin.
+	apps map[Origin]App
+	// names holds the apps which claimed each name, in order.
+	names map[string][]App
+}
+
+// register sets the App of the records of the given origin.
+func (c *claims) register(origin Origin, app App) {
+	if c.apps == nil {
+		c.apps = map[Origin]App{}
+	}
+	c.apps[origin] = app
+}
+
+// claim records that the given record claims its name for its app. returns
+// the app along with whether the record belongs to one. PTR records, named
+// after addresses, don't claim their names.
+func (c *claims) claim(rr RR) (App, bool) {
+	app, ok := c.apps[rr.Origin]
+	if !ok || rr.Type == dns.TypePTR {
+		return app, false
+	}
+	if c.names == nil {
+		c.names = map[string][]App{}
+	}
+	for _, other := range c.names[rr.Name] {
+		if other == app {
+			return app, true
+		}
+	}
+	c.names[rr.Name] = append(c.names[rr.Name], app)
+	return app, true
+}
+
+// first returns the first app which claimed the given name.
+func (c *claims) first(name string) App {
+	return c.names[name][0]
+}
+
+// collisions returns the names claimed by several apps, sorted by name.
+func (c *claims) collisions() []Collision {
+	var cs []Collision
+	for name, apps := range c.names {
+		if len(apps) > 1 {
+			cs = append(cs, Collision{Name: name, Apps: apps})
+		}
+	}
+	sort.Sort(byCollisionName(cs))
+	return cs
+}
+
+type byCollisionName []Collision
+
+func (cs byCollisionName) Len() int           { return len(cs) }
+func (cs byCollisionName) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
+func (cs byCollisionName) Less(i, j int) bool { return cs[i].Name < cs[j].Name }
+
+// resolveCollisions sets the Collisions of the generator, logging and
+// counting the ones it doesn't know of yet, and drops the records of colliding
+// names if the configured CollisionPolicy says so.
+func (rg *RecordGenerator) resolveCollisions() {
+	rg.Collisions = rg.claims.collisions()
+	if len(rg.Collisions) == 0 {
+		return
+	}
+	known := make(map[string][]App, len(rg.known))
+	for _, c := range rg.known {
+		known[c.Name] = c.Apps
+	}
+	for _, c := range rg.Collisions {
+		if sameApps(known[c.Name], c.Apps) {
+			continue
+		}
+		logging.Verbose.Printf("name %s claimed by %d apps (policy %q); see /v1/collisions: %+v",
+			c.Name, len(c.Apps), rg.config.CollisionPolicy, c.Apps)
+		logging.CurLog.NameCollisions.Inc()
+	}
+
+	if rg.config.CollisionPolicy != DropPolicy {
+		return
+	}
+	colliding := make(map[string]struct{}, len(rg.Collisions))
+	for _, c := range rg.Collisions {
+		colliding[c.Name] = struct{}{}
+	}
+	rs := NewRecordSet()
+	for _, rr := range rg.Records.All() {
+		_, ok := colliding[rr.Name]
+		if _, owned := rg.claims.apps[rr.Origin]; ok && owned && rr.Type != dns.TypePTR {
+			continue
+		}
+		rs.insert(rr)
+	}
+	rg.Records = rs
+}
+
+// sameApps reports whether the given lists hold the same apps, in order.
+func sameApps(a, b []App) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -25,14 +25,63 @@ type Config struct {
 	Timeout int
 	// Timeout in seconds waiting for the master to return data from StateJson
 	StateTimeoutSeconds int
+	// StateAPI selects the master endpoint the state is read from:
+	// "state.json" for the legacy /master/state.json endpoint or "v1" for the
+	// GET_STATE call of the v1 operator API (default "state.json")
+	StateAPI string
+	// StateEncoding selects the encoding of the v1 operator API calls:
+	// "json" or "protobuf" (default "json")
+	StateEncoding string
+	// StateUpdates selects how the state is kept up to date: "poll" to read
+	// it every RefreshSeconds or "stream" to apply the events of the
+	// SUBSCRIBE call of the v1 operator API as they happen, resubscribing
+	// every RefreshSeconds for a full snapshot (default "poll")
+	StateUpdates string
+	// StreamIntervalSeconds is the minimum interval in seconds between the
+	// record updates of a "stream", which applies the events of shorter
+	// intervals together; 0 updates records after every batch of events
+	// (default 1)
+	StreamIntervalSeconds int
+	// MesosHTTPSOn makes requests to masters use HTTPS (default false)
+	MesosHTTPSOn bool
+	// CACertFile is the PEM bundle of the CAs verifying the certificates of
+	// masters, instead of the system ones
+	CACertFile string
+	// CertFile and KeyFile are the PEM client certificate and key presented
+	// to masters
+	CertFile string
+	KeyFile  string
+	// MesosInsecureSkipVerify skips the verification of the certificates of
+	// masters (default false)
+	MesosInsecureSkipVerify bool
+	// MesosAuthentication selects how requests to masters authenticate: ""
+	// for not at all, "basic" for HTTP basic authentication with
+	// MesosCredentials or "token" for bearer tokens read from MesosTokenFile
+	MesosAuthentication string
+	// MesosCredentials holds the principal and secret of basic authentication
+	MesosCredentials Credentials
+	// MesosTokenFile is the file holding the bearer token, re-read when it
+	// changes, expires or is rejected
+	MesosTokenFile string
 	// Zookeeper Detection Timeout: how long in seconds to wait for Zookeeper to
 	// be initially responsive. Default is 30 and 0 means no timeout.
 	ZkDetectionTimeout int
 	// NOTE(tsenart): HTTPPort, DNSOn and HTTPOn have defined JSON keys for
 	// backwards compatibility with external API clients.
 	HTTPPort int `json:"HttpPort"`
+	// HostCacheSeconds: how long in seconds the addresses of framework and
+	// slave hostnames are cached; 0 looks them up on every refresh (default 0)
+	HostCacheSeconds int
+	// HostNegativeCacheSeconds: how long in seconds failed hostname lookups
+	// are cached (default 30)
+	HostNegativeCacheSeconds int
+	// HostLookupWorkers: the number of concurrent hostname lookups (default 8)
+	HostLookupWorkers int
 	// TTL: the TTL value used for SRV and A records (default 60)
 	TTL int32
+	// TTLs maps record classes ("task", "agent", "master", "framework",
+	// "listener") to the TTL of their records, overriding TTL
+	TTLs map[string]uint32
 	// SOA record fields (see http://tools.ietf.org/html/rfc1035#page-18)
 	SOASerial  uint32 // initial version number (incremented on refresh)
 	SOARefresh uint32 // refresh interval
@@ -64,32 +113,147 @@ type Config struct {
 	ExternalOn bool
 	// EnforceRFC952 will enforce an older, more strict set of rules for DNS labels
 	EnforceRFC952 bool
+	// EnforceVisibility hides tasks with a FRAMEWORK DiscoveryInfo visibility
+	// and publishes EXTERNAL ones in the ExternalDomain too
+	EnforceVisibility bool
+	// ExternalDomain: name of the domain EXTERNAL visible tasks are published
+	// in, in addition to Domain, if EnforceVisibility is set (e.g. "mesos.example.com")
+	ExternalDomain string
+	// IPMode selects the address families of published records: "ipv4" for
+	// A records only, "ipv6" for AAAA records only or "dual" for both
+	IPMode string
+	// ReverseZones lists the CIDRs whose reverse zones (in-addr.arpa and
+	// ip6.arpa) mesos-dns is authoritative for, e.g. ["10.0.0.0/8"]
+	ReverseZones []string
+	// TXTLabels lists the task and DiscoveryInfo label keys published as
+	// "key=value" TXT records of each task name
+	TXTLabels []string
+	// SRVWeightResource is the scalar task resource ("cpus", "mem" or
+	// "disk") SRV weights are proportional to, unless set by a task label
+	SRVWeightResource string
+	// TaskStates lists the Mesos states of the tasks which get records, e.g.
+	// ["TASK_RUNNING", "TASK_KILLING"] (default ["TASK_RUNNING"])
+	TaskStates []string
+	// DrainSeconds is the grace period in seconds during which tasks which
+	// stopped keep their records (default 0)
+	DrainSeconds int
+	// DrainTTL, if non-zero, overrides the TTL of the records of draining tasks
+	DrainTTL uint32
+	// HealthPolicy selects the tasks published according to the results of
+	// their Mesos health checks: "all", "healthy" for all but unhealthy ones,
+	// or "healthy-first" for unhealthy ones only when no healthy task has the
+	// same name
+	HealthPolicy string
+	// CollisionPolicy selects the records of names claimed by several apps:
+	// "merge" for all of them, "first-wins" for the first app's only, or
+	// "drop" for none
+	CollisionPolicy string
+	// AliasMode selects how the aliases of tasks, set by their DNS_ALIASES
+	// label, are published: "direct" for copies of the A and SRV records of
+	// the task name, or "cname" for CNAME records of it
+	AliasMode string
+	// DiscoveryZones lists the DiscoveryInfo fields ("environment",
+	// "location", "version") whose values scope additional task names, e.g.
+	// task.prod.framework.domain
+	DiscoveryZones []string
+	// AgentAttributes lists the slave attributes whose values scope
+	// additional slave and task names, e.g. slave.rack-12.domain and
+	// task.framework.zone-b.domain
+	AgentAttributes []string
+	// NameTemplates declares the names generated for each task in addition
+	// to its canonical name, e.g. [{"Name": "{name}.{framework}", "Target":
+	// "task"}]. See NameTemplate for the supported placeholders.
+	NameTemplates []NameTemplate
+	// IPFilters maps record classes ("task", "agent", "master", "framework",
+	// "listener") to the CIDRs their A records are allowed or denied in
+	IPFilters map[string]IPFilter
+	// StaticFiles lists RFC 1035 zone files and JSON record files (with a
+	// .json extension) whose records are published along with the generated
+	// ones. Files are re-read when they change.
+	StaticFiles []string
 }
 
+// IP modes selecting the address families of published records.
+const (
+	IPv4Mode = "ipv4"
+	IPv6Mode = "ipv6"
+	DualMode = "dual"
+)
+
+// Health policies selecting the tasks published according to their health.
+const (
+	AllPolicy          = "all"
+	HealthyPolicy      = "healthy"
+	HealthyFirstPolicy = "healthy-first"
+)
+
+// State APIs selecting the master endpoint the state is read from.
+const (
+	LegacyStateAPI   = "state.json"
+	OperatorStateAPI = "v1"
+)
+
+// State encodings selecting the encoding of v1 operator API calls.
+const (
+	JSONStateEncoding     = "json"
+	ProtobufStateEncoding = "protobuf"
+)
+
+// State updates selecting how the state is kept up to date.
+const (
+	PollStateUpdates   = "poll"
+	StreamStateUpdates = "stream"
+)
+
+// Mesos authentication modes selecting how requests to masters authenticate.
+const (
+	NoMesosAuthentication    = ""
+	BasicMesosAuthentication = "basic"
+	TokenMesosAuthentication = "token"
+)
+
+// Alias modes selecting how the aliases of tasks are published.
+const (
+	DirectAliasMode = "direct"
+	CNAMEAliasMode  = "cname"
+)
+
 // NewConfig return the default config of the resolver
 func NewConfig() Config {
 	return Config{
-		ZkDetectionTimeout:  30,
-		RefreshSeconds:      60,
-		TTL:                 60,
-		Domain:              "mesos",
-		Port:                53,
-		Timeout:             5,
-		StateTimeoutSeconds: 300,
-		SOARname:            "root.ns1.mesos",
-		SOAMname:            "ns1.mesos",
-		SOARefresh:          60,
-		SOARetry:            600,
-		SOAExpire:           86400,
-		SOAMinttl:           60,
-		Resolvers:           []string{"8.8.8.8"},
-		Listener:            "0.0.0.0",
-		HTTPPort:            8123,
-		DNSOn:               true,
-		HTTPOn:              true,
-		ExternalOn:          true,
-		RecurseOn:           true,
-		IPSources:           []string{"netinfo", "mesos", "host"},
+		ZkDetectionTimeout:       30,
+		RefreshSeconds:           60,
+		TTL:                      60,
+		Domain:                   "mesos",
+		Port:                     53,
+		Timeout:                  5,
+		StateTimeoutSeconds:      300,
+		StateAPI:                 LegacyStateAPI,
+		StateEncoding:            JSONStateEncoding,
+		StateUpdates:             PollStateUpdates,
+		StreamIntervalSeconds:    1,
+		HostCacheSeconds:         0,
+		HostNegativeCacheSeconds: 30,
+		HostLookupWorkers:        defaultHostLookupWorkers,
+		SOARname:                 "root.ns1.mesos",
+		SOAMname:                 "ns1.mesos",
+		SOARefresh:               60,
+		SOARetry:                 600,
+		SOAExpire:                86400,
+		SOAMinttl:                60,
+		Resolvers:                []string{"8.8.8.8"},
+		Listener:                 "0.0.0.0",
+		HTTPPort:                 8123,
+		DNSOn:                    true,
+		HTTPOn:                   true,
+		ExternalOn:               true,
+		RecurseOn:                true,
+		IPSources:                []string{"netinfo", "mesos", "host"},
+		IPMode:                   DualMode,
+		HealthPolicy:             AllPolicy,
+		CollisionPolicy:          MergePolicy,
+		AliasMode:                DirectAliasMode,
+		TaskStates:               []string{"TASK_RUNNING"},
 	}
 }
 
@@ -118,11 +282,92 @@ func SetConfig(cjson string) Config {
 		}
 	}
 
+	if err = validateStateAPI(c.StateAPI, c.StateEncoding); err != nil {
+		logging.Error.Fatalf("StateAPI validation failed: %v", err)
+	}
+	if err = validateStateUpdates(c.StateUpdates, c.StateAPI); err != nil {
+		logging.Error.Fatalf("StateUpdates validation failed: %v", err)
+	}
+	if c.StreamIntervalSeconds < 0 {
+		logging.Error.Fatalf("StreamIntervalSeconds validation failed: must not be negative")
+	}
+	if err = validateMesosTLS(*c); err != nil {
+		logging.Error.Fatalf("MesosHTTPSOn validation failed: %v", err)
+	}
+	if err = validateMesosAuthentication(c.MesosAuthentication, c.MesosCredentials, c.MesosTokenFile); err != nil {
+		logging.Error.Fatalf("MesosAuthentication validation failed: %v", err)
+	}
+
 	if err = validateIPSources(c.IPSources); err != nil {
 		logging.Error.Fatalf("IPSources validation failed: %v", err)
 	}
 
+	if err = validateIPMode(c.IPMode); err != nil {
+		logging.Error.Fatalf("IPMode validation failed: %v", err)
+	}
+
+	if err = validateIPFilters(c.IPFilters); err != nil {
+		logging.Error.Fatalf("IPFilters validation failed: %v", err)
+	}
+
+	if err = validateReverseZones(c.ReverseZones); err != nil {
+		logging.Error.Fatalf("ReverseZones validation failed: %v", err)
+	}
+
+	if err = validateSRVWeightResource(c.SRVWeightResource); err != nil {
+		logging.Error.Fatalf("SRVWeightResource validation failed: %v", err)
+	}
+
+	if err = validateHostCache(c.HostCacheSeconds, c.HostNegativeCacheSeconds, c.HostLookupWorkers); err != nil {
+		logging.Error.Fatalf("host cache validation failed: %v", err)
+	}
+
+	if err = validateTaskStates(c.TaskStates); err != nil {
+		logging.Error.Fatalf("TaskStates validation failed: %v", err)
+	}
+
+	if c.DrainSeconds < 0 {
+		logging.Error.Fatalf("DrainSeconds validation failed: must not be negative")
+	}
+
+	if err = validateHealthPolicy(c.HealthPolicy); err != nil {
+		logging.Error.Fatalf("HealthPolicy validation failed: %v", err)
+	}
+
+	if err = validateCollisionPolicy(c.CollisionPolicy); err != nil {
+		logging.Error.Fatalf("CollisionPolicy validation failed: %v", err)
+	}
+
+	if err = validateAliasMode(c.AliasMode); err != nil {
+		logging.Error.Fatalf("AliasMode validation failed: %v", err)
+	}
+
+	if err = validateTTLs(c.TTLs); err != nil {
+		logging.Error.Fatalf("TTLs validation failed: %v", err)
+	}
+
+	if err = validateNameTemplates(c.NameTemplates, c.EnforceRFC952); err != nil {
+		logging.Error.Fatalf("NameTemplates validation failed: %v", err)
+	}
+
+	if err = validateDiscoveryZones(c.DiscoveryZones); err != nil {
+		logging.Error.Fatalf("DiscoveryZones validation failed: %v", err)
+	}
+
+	if err = validateAgentAttributes(c.AgentAttributes); err != nil {
+		logging.Error.Fatalf("AgentAttributes validation failed: %v", err)
+	}
+
 	c.Domain = strings.ToLower(c.Domain)
+	c.ExternalDomain = strings.ToLower(strings.TrimSuffix(c.ExternalDomain, "."))
+
+	if err = validateStaticFiles(c.StaticFiles, c.Domain); err != nil {
+		logging.Error.Fatalf("StaticFiles validation failed: %v", err)
+	}
+
+	if err = validateExternalDomain(c.ExternalDomain, c.Domain); err != nil {
+		logging.Error.Fatalf("ExternalDomain validation failed: %v", err)
+	}
 
 	// SOA record fields
 	c.SOARname = strings.TrimRight(strings.Replace(c.SOARname, "@", ".", -1), ".") + "."
@@ -140,8 +385,24 @@ func SetConfig(cjson string) Config {
 	logging.Verbose.Println("   - Port: ", c.Port)
 	logging.Verbose.Println("   - DnsOn: ", c.DNSOn)
 	logging.Verbose.Println("   - TTL: ", c.TTL)
+	logging.Verbose.Println("   - TTLs: ", c.TTLs)
 	logging.Verbose.Println("   - Timeout: ", c.Timeout)
 	logging.Verbose.Println("   - StateTimeoutSeconds: ", c.StateTimeoutSeconds)
+	logging.Verbose.Println("   - StateAPI: ", c.StateAPI)
+	logging.Verbose.Println("   - StateEncoding: ", c.StateEncoding)
+	logging.Verbose.Println("   - StateUpdates: ", c.StateUpdates)
+	logging.Verbose.Println("   - StreamIntervalSeconds: ", c.StreamIntervalSeconds)
+	logging.Verbose.Println("   - MesosHTTPSOn: ", c.MesosHTTPSOn)
+	logging.Verbose.Println("   - CACertFile: ", c.CACertFile)
+	logging.Verbose.Println("   - CertFile: ", c.CertFile)
+	logging.Verbose.Println("   - KeyFile: ", c.KeyFile)
+	logging.Verbose.Println("   - MesosInsecureSkipVerify: ", c.MesosInsecureSkipVerify)
+	logging.Verbose.Println("   - MesosAuthentication: ", c.MesosAuthentication)
+	logging.Verbose.Println("   - MesosCredentials.Principal: ", c.MesosCredentials.Principal)
+	logging.Verbose.Println("   - MesosTokenFile: ", c.MesosTokenFile)
+	logging.Verbose.Println("   - HostCacheSeconds: ", c.HostCacheSeconds)
+	logging.Verbose.Println("   - HostNegativeCacheSeconds: ", c.HostNegativeCacheSeconds)
+	logging.Verbose.Println("   - HostLookupWorkers: ", c.HostLookupWorkers)
 	logging.Verbose.Println("   - Resolvers: " + strings.Join(c.Resolvers, ", "))
 	logging.Verbose.Println("   - ExternalOn: ", c.ExternalOn)
 	logging.Verbose.Println("   - SOAMname: " + c.SOAMname)
@@ -156,7 +417,24 @@ func SetConfig(cjson string) Config {
 	logging.Verbose.Println("   - HttpOn: ", c.HTTPOn)
 	logging.Verbose.Println("   - ConfigFile: ", c.File)
 	logging.Verbose.Println("   - EnforceRFC952: ", c.EnforceRFC952)
+	logging.Verbose.Println("   - EnforceVisibility: ", c.EnforceVisibility)
+	logging.Verbose.Println("   - ExternalDomain: " + c.ExternalDomain)
 	logging.Verbose.Println("   - IPSources: ", c.IPSources)
+	logging.Verbose.Println("   - IPMode: ", c.IPMode)
+	logging.Verbose.Println("   - IPFilters: ", c.IPFilters)
+	logging.Verbose.Println("   - ReverseZones: ", c.ReverseZones)
+	logging.Verbose.Println("   - TXTLabels: ", c.TXTLabels)
+	logging.Verbose.Println("   - SRVWeightResource: ", c.SRVWeightResource)
+	logging.Verbose.Println("   - TaskStates: ", c.TaskStates)
+	logging.Verbose.Println("   - DrainSeconds: ", c.DrainSeconds)
+	logging.Verbose.Println("   - DrainTTL: ", c.DrainTTL)
+	logging.Verbose.Println("   - HealthPolicy: ", c.HealthPolicy)
+	logging.Verbose.Println("   - CollisionPolicy: ", c.CollisionPolicy)
+	logging.Verbose.Println("   - AliasMode: ", c.AliasMode)
+	logging.Verbose.Println("   - NameTemplates: ", c.NameTemplates)
+	logging.Verbose.Println("   - DiscoveryZones: ", c.DiscoveryZones)
+	logging.Verbose.Println("   - AgentAttributes: ", c.AgentAttributes)
+	logging.Verbose.Println("   - StaticFiles: ", c.StaticFiles)
 
 	return *c
 }
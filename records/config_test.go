@@ -21,6 +21,10 @@ func TestNewConfigValidates(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	err = validateIPMode(c.IPMode)
+	if err != nil {
+		t.Error(err)
+	}
 	err = validateResolvers(c.Resolvers)
 	if err != nil {
 		t.Error(err)
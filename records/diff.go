@@ -0,0 +1,70 @@
+package records
+
+import (
+	"sort"
+)
+
+// Diff holds the changes between two generations of records.
+type Diff struct {
+	// Added and Removed hold the records which only exist in the new and the
+	// old generation, respectively.
+	Added, Removed []RR
+	// AddedNames and RemovedNames hold the names which have records only in
+	// the new and the old generation, respectively.
+	AddedNames, RemovedNames []string
+}
+
+// Empty returns whether the Diff holds no changes.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// DiffRecords returns the changes from the records in prev to those in next.
+// Either may be nil. Records, and names, are sorted.
+func DiffRecords(prev, next *RecordSet) Diff {
+	var d Diff
+	d.Added, d.AddedNames = missing(next, prev)
+	d.Removed, d.RemovedNames = missing(prev, next)
+	return d
+}
+
+// missing returns the records and names in a which aren't in b. Records
+// whose priority, weight or TTL changed count as missing too.
+func missing(a, b *RecordSet) (rrs []RR, names []string) {
+	for _, rr := range a.All() {
+		if other, ok := b.lookup(rr); !ok || !sameData(rr, other) {
+			rrs = append(rrs, rr)
+		}
+	}
+	if a != nil {
+		for name := range a.names {
+			if !b.Has(name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Sort(byNameTypeValue(rrs))
+	sort.Strings(names)
+	return rrs, names
+}
+
+// sameData returns whether the given records, of the same name, type and
+// value, also have the same priority, weight and TTL.
+func sameData(a, b RR) bool {
+	return a.Priority == b.Priority && a.Weight == b.Weight && a.TTL == b.TTL
+}
+
+type byNameTypeValue []RR
+
+func (rrs byNameTypeValue) Len() int      { return len(rrs) }
+func (rrs byNameTypeValue) Swap(i, j int) { rrs[i], rrs[j] = rrs[j], rrs[i] }
+func (rrs byNameTypeValue) Less(i, j int) bool {
+	a, b := rrs[i], rrs[j]
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	return a.Value() < b.Value()
+}
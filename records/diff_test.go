@@ -0,0 +1,60 @@
+package records
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDiffRecords(t *testing.T) {
+	a := func(name, ip string) RR {
+		return RR{Name: name, Type: dns.TypeA, IP: net.ParseIP(ip).To4()}
+	}
+	srv := func(name string, weight uint16) RR {
+		return RR{Name: name, Type: dns.TypeSRV, Target: "web.mesos.", Port: 80, Weight: weight}
+	}
+	prev := NewRecordSet(
+		a("web.mesos.", "10.0.0.1"),
+		a("web.mesos.", "10.0.0.2"),
+		a("old.mesos.", "10.0.0.3"),
+		srv("_web._tcp.mesos.", 1),
+	)
+	next := NewRecordSet(
+		a("web.mesos.", "10.0.0.2"),
+		a("web.mesos.", "10.0.0.4"),
+		a("new.mesos.", "10.0.0.3"),
+		srv("_web._tcp.mesos.", 2),
+	)
+
+	for i, tt := range []struct {
+		prev, next *RecordSet
+		want       Diff
+	}{
+		{prev, prev, Diff{}},
+		{nil, nil, Diff{}},
+		{nil, NewRecordSet(a("web.mesos.", "10.0.0.1")), Diff{
+			Added:      []RR{a("web.mesos.", "10.0.0.1")},
+			AddedNames: []string{"web.mesos."},
+		}},
+		{prev, next, Diff{
+			Added: []RR{
+				srv("_web._tcp.mesos.", 2),
+				a("new.mesos.", "10.0.0.3"),
+				a("web.mesos.", "10.0.0.4"),
+			},
+			Removed: []RR{
+				srv("_web._tcp.mesos.", 1),
+				a("old.mesos.", "10.0.0.3"),
+				a("web.mesos.", "10.0.0.1"),
+			},
+			AddedNames:   []string{"new.mesos."},
+			RemovedNames: []string{"old.mesos."},
+		}},
+	} {
+		if got := DiffRecords(tt.prev, tt.next); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: got %+v, want %+v", i, got, tt.want)
+		}
+	}
+}
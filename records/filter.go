@@ -0,0 +1,81 @@
+package records
+
+import (
+	"net"
+)
+
+// Record classes group generated records by the kind of Mesos entity they
+// describe. They're used to select per-class configuration such as IP filters.
+const (
+	TaskClass      = "task"
+	AgentClass     = "agent"
+	MasterClass    = "master"
+	FrameworkClass = "framework"
+	ListenerClass  = "listener"
+)
+
+// recordClasses lists all known record classes.
+var recordClasses = []string{TaskClass, AgentClass, MasterClass, FrameworkClass, ListenerClass}
+
+// IPFilter holds the CIDRs an address must (Allow) or must not (Deny) be in
+// for an A record to be published. An empty Allow list allows every address.
+type IPFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+// ipFilter is the parsed form of an IPFilter.
+type ipFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// ipFilters maps record classes to their parsed IPFilter.
+type ipFilters map[string]ipFilter
+
+// newIPFilters parses the given per-class IPFilters. Invalid CIDRs are
+// skipped; they're rejected by config validation beforehand.
+func newIPFilters(fs map[string]IPFilter) ipFilters {
+	parsed := make(ipFilters, len(fs))
+	for class, f := range fs {
+		parsed[class] = ipFilter{allow: parseCIDRs(f.Allow), deny: parseCIDRs(f.Deny)}
+	}
+	return parsed
+}
+
+// allowed returns whether the given address may be published for the given
+// record class. Addresses which aren't IPs (i.e. unresolved hostnames) are
+// always allowed.
+func (fs ipFilters) allowed(class, addr string) bool {
+	f, ok := fs[class]
+	if !ok {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return true
+	}
+	if len(f.allow) > 0 && !containsIP(f.allow, ip) {
+		return false
+	}
+	return !containsIP(f.deny, ip)
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCIDRs(cidrs []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if _, n, err := net.ParseCIDR(cidr); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
@@ -0,0 +1,61 @@
+package records
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mesosphere/mesos-dns/records/labels"
+	"github.com/miekg/dns"
+)
+
+func TestIPFilters_Allowed(t *testing.T) {
+	fs := newIPFilters(map[string]IPFilter{
+		TaskClass:   {Deny: []string{"172.17.0.0/16"}},
+		AgentClass:  {Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.1.0.0/16"}},
+		MasterClass: {Allow: []string{"bogus"}},
+	})
+	for i, tt := range []struct {
+		class, addr string
+		want        bool
+	}{
+		{TaskClass, "172.17.0.2", false},
+		{TaskClass, "172.18.0.2", true},
+		{TaskClass, "hostname", true},
+		{AgentClass, "10.0.0.1", true},
+		{AgentClass, "10.1.0.1", false},
+		{AgentClass, "192.168.0.1", false},
+		{MasterClass, "1.2.3.4", true},
+		{FrameworkClass, "172.17.0.2", true},
+	} {
+		if got := fs.allowed(tt.class, tt.addr); got != tt.want {
+			t.Errorf("test #%d: allowed(%q, %q): got %v, want %v", i, tt.class, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_IPFilters(t *testing.T) {
+	c := NewConfig()
+	c.IPFilters = map[string]IPFilter{
+		AgentClass:  {Deny: []string{"1.2.3.10/32"}},
+		MasterClass: {Deny: []string{"144.76.157.0/24"}},
+	}
+	rg := NewRecordGenerator(0, WithConfig(c))
+	*rg = testRecordGeneratorWith(t, rg, labels.RFC1123, []string{"host"})
+
+	if got, want := values(rg.Records, "slave.mesos.", dns.TypeA), []string{"1.2.3.11", "1.2.3.12"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("slave.mesos.: got %q, want %q", got, want)
+	}
+	if got := values(rg.Records, "leader.mesos.", dns.TypeA); len(got) != 0 {
+		t.Errorf("leader.mesos.: got %q, want none", got)
+	}
+	// slave.mesos. and 2.agents.mesos.
+	if got, want := rg.Filtered[AgentClass], 2; got != want {
+		t.Errorf("Filtered[%q]: got %d, want %d", AgentClass, got, want)
+	}
+	if got, want := rg.Filtered[MasterClass], 3; got != want {
+		t.Errorf("Filtered[%q]: got %d, want %d", MasterClass, got, want)
+	}
+	if got, want := rg.Filtered[TaskClass], 0; got != want {
+		t.Errorf("Filtered[%q]: got %d, want %d", TaskClass, got, want)
+	}
+}
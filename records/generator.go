@@ -3,48 +3,158 @@
 package records
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/mesosphere/mesos-dns/errorutil"
 	"github.com/mesosphere/mesos-dns/logging"
 	"github.com/mesosphere/mesos-dns/records/labels"
 	"github.com/mesosphere/mesos-dns/records/state"
+	"github.com/mesosphere/mesos-dns/records/state/operator"
+	"github.com/miekg/dns"
 	"github.com/tv42/zbase32"
 )
 
-// Map host/service name to DNS answer
-// REFACTOR - when discoveryinfo is integrated
-// Will likely become map[string][]discoveryinfo
-type rrs map[string][]string
-
 // RecordGenerator contains DNS records and methods to access and manipulate
 // them. TODO(kozyraki): Refactor when discovery id is available.
 type RecordGenerator struct {
-	As         rrs
-	SRVs       rrs
-	SlaveIPs   map[string]string
+	// Records holds the A, AAAA, SRV, PTR, TXT and CNAME records generated
+	// from the last state, along with the records of the static files. It's replaced, never modified, on every InsertState.
+	Records  *RecordSet
+	SlaveIPs map[string]string
+	// Filtered counts the A records dropped by IP filters, per record class.
+	Filtered map[string]int
+	// Collisions lists the names claimed by several apps.
+	Collisions []Collision
+	// LiveTasks holds the IDs of the tasks of the last state which are in
+	// one of the TaskStates on a known agent, be they published or not,
+	// e.g. because they're failing their health checks.
+	LiveTasks  map[string]struct{}
 	httpClient http.Client
+	config     Config
+	filters    ipFilters
+	templates  []nameTemplate
+	// slaveAddrs holds all the addresses of each slave, by slave ID.
+	slaveAddrs map[string][]string
+	// slaveAttrs holds the attributes of each slave, by slave ID.
+	slaveAttrs map[string]state.Attributes
+	// taskTTLs holds the TTLs set by task labels, by task ID.
+	taskTTLs map[string]uint32
+	// unhealthy holds the IDs of published tasks failing their health checks.
+	unhealthy map[string]struct{}
+	// hosts caches hostname lookups across generations, if set.
+	hosts *HostCache
+	// resolved holds the lookups of the hostnames of the current state.
+	resolved map[string]hostLookup
+	// claims tracks the apps which claimed each name.
+	claims claims
+	// owned holds the names of the records of the current state other than
+	// the tasks', which aliases can't take.
+	owned map[string]struct{}
+	// static reads the configured StaticFiles across generations, if set.
+	static *StaticFiles
+	// known holds the collisions of the previous generation, which aren't
+	// logged again.
+	known []Collision
+}
+
+// SRVOptions holds the priority and weight of a SRV record target as defined
+// in RFC 2782.
+type SRVOptions struct {
+	Priority uint16
+	Weight   uint16
+}
+
+const (
+	// SRVPriorityLabel is the key of the task label holding the priority of
+	// the task's SRV records.
+	SRVPriorityLabel = "DNS_SRV_PRIORITY"
+	// SRVWeightLabel is the key of the task label holding the weight of the
+	// task's SRV records.
+	SRVWeightLabel = "DNS_SRV_WEIGHT"
+	// TTLLabel is the key of the task label holding the TTL, in seconds, of
+	// the task's records.
+	TTLLabel = "DNS_TTL"
+	// FrameworkVisibility, ClusterVisibility and ExternalVisibility are the
+	// DiscoveryInfo visibilities of tasks.
+	FrameworkVisibility = "FRAMEWORK"
+	ClusterVisibility   = "CLUSTER"
+	ExternalVisibility  = "EXTERNAL"
+	// maxResourceWeight is the SRV weight of the task with the largest
+	// SRVWeightResource.
+	maxResourceWeight = 100
+)
+
+// Option is a functional option for RecordGenerators.
+type Option func(*RecordGenerator)
+
+// WithConfig returns an Option that configures a RecordGenerator with the
+// given Config. ParseState overrides it with the Config it's given.
+func WithConfig(c Config) Option {
+	return func(rg *RecordGenerator) { rg.configure(c) }
+}
+
+// WithHostCache returns an Option that makes a RecordGenerator look up
+// hostnames through the given HostCache.
+func WithHostCache(c *HostCache) Option {
+	return func(rg *RecordGenerator) { rg.hosts = c }
+}
+
+// WithTransport returns an Option that makes a RecordGenerator send its
+// requests to masters through the given http.RoundTripper, e.g. one returned
+// by NewMasterTransport.
+func WithTransport(t http.RoundTripper) Option {
+	return func(rg *RecordGenerator) { rg.httpClient.Transport = t }
+}
+
+// WithStaticFiles returns an Option that makes a RecordGenerator read the
+// configured static files through the given StaticFiles.
+func WithStaticFiles(s *StaticFiles) Option {
+	return func(rg *RecordGenerator) { rg.static = s }
+}
+
+// WithKnownCollisions returns an Option that makes a RecordGenerator log and
+// count only the collisions it finds which aren't among the given ones, e.g.
+// the Collisions of the previous generation.
+func WithKnownCollisions(cs []Collision) Option {
+	return func(rg *RecordGenerator) { rg.known = cs }
 }
 
 // NewRecordGenerator returns a RecordGenerator that's been configured with a timeout.
-func NewRecordGenerator(httpTimeout time.Duration) *RecordGenerator {
+func NewRecordGenerator(httpTimeout time.Duration, options ...Option) *RecordGenerator {
 	rg := &RecordGenerator{httpClient: http.Client{Timeout: httpTimeout}}
+	for _, opt := range options {
+		opt(rg)
+	}
 	return rg
 }
 
+// configure sets the Config used by the generator along with any state
+// derived from it.
+func (rg *RecordGenerator) configure(c Config) {
+	rg.config = c
+	rg.filters = newIPFilters(c.IPFilters)
+	rg.templates = parseNameTemplates(c.NameTemplates)
+}
+
 // ParseState retrieves and parses the Mesos master /state.json and converts it
 // into DNS records.
 func (rg *RecordGenerator) ParseState(c Config, masters ...string) error {
+	rg.configure(c)
+
 	// find master -- return if error
 	sj, err := rg.findMaster(masters...)
 	if err != nil {
@@ -57,6 +167,14 @@ func (rg *RecordGenerator) ParseState(c Config, masters ...string) error {
 		return err
 	}
 
+	return rg.LoadState(c, sj, masters...)
+}
+
+// LoadState converts the given state of the cluster, e.g. kept up to date
+// with the events of the master, into DNS records.
+func (rg *RecordGenerator) LoadState(c Config, sj state.State, masters ...string) error {
+	rg.configure(c)
+
 	hostSpec := labels.RFC1123
 	if c.EnforceRFC952 {
 		hostSpec = labels.RFC952
@@ -114,16 +232,15 @@ func (rg *RecordGenerator) findMaster(masters ...string) (state.State, error) {
 	return sj, errors.New("no master")
 }
 
-// Loads state.json from mesos master
+// Loads state.json from mesos master, or the state returned by the GET_STATE
+// call of its v1 operator API if the StateAPI says so
 func (rg *RecordGenerator) loadFromMaster(ip string, port string) (state.State, error) {
-	// REFACTOR: state.json security
+	if rg.config.StateAPI == OperatorStateAPI {
+		return rg.loadFromOperatorAPI(ip, port)
+	}
 
 	var sj state.State
-	u := url.URL{
-		Scheme: "http",
-		Host:   net.JoinHostPort(ip, port),
-		Path:   "/master/state.json",
-	}
+	u := masterURL(rg.config, ip, port, "/master/state.json")
 
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
@@ -155,6 +272,97 @@ func (rg *RecordGenerator) loadFromMaster(ip string, port string) (state.State,
 	return sj, nil
 }
 
+// loadFromOperatorAPI loads the state of the cluster with the GET_STATE call
+// of the v1 operator API of the given master, along with the leading master
+// with the GET_MASTER call.
+func (rg *RecordGenerator) loadFromOperatorAPI(ip, port string) (state.State, error) {
+	master, err := rg.callOperatorAPI(ip, port, operator.GetMaster)
+	if err != nil {
+		logging.Error.Println(err)
+		return state.State{}, err
+	}
+	st, err := rg.callOperatorAPI(ip, port, operator.GetState)
+	if err != nil {
+		logging.Error.Println(err)
+		return state.State{}, err
+	}
+	if master.GetMaster == nil || st.GetState == nil {
+		return state.State{}, errors.New("empty operator API response")
+	}
+
+	sj, err := st.GetState.State(master.GetMaster.MasterInfo.Leader())
+	if err != nil {
+		logging.Error.Println(err)
+		return state.State{}, err
+	}
+	return sj, nil
+}
+
+// callOperatorAPI makes the given call to the v1 operator API of the given
+// master in the configured StateEncoding and returns its response.
+func (rg *RecordGenerator) callOperatorAPI(ip, port string, t operator.CallType) (*operator.Response, error) {
+	codec := newOperatorCodec(rg.config.StateEncoding)
+	req, err := codec.request(masterURL(rg.config, ip, port, operator.Path), t)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rg.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer errorutil.Ignore(resp.Body.Close)
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s call to %s: %s: %s", t, req.URL.Host, resp.Status, bytes.TrimSpace(data))
+	}
+
+	var r operator.Response
+	if err := codec.unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s call to %s: %v", t, req.URL.Host, err)
+	}
+	return &r, nil
+}
+
+// operatorCodec encodes v1 operator API calls and decodes their responses in
+// a StateEncoding.
+type operatorCodec struct {
+	contentType string
+	marshal     func(proto.Message) ([]byte, error)
+	unmarshal   func([]byte, proto.Message) error
+}
+
+// newOperatorCodec returns the operatorCodec of the given StateEncoding.
+func newOperatorCodec(encoding string) operatorCodec {
+	if encoding == ProtobufStateEncoding {
+		return operatorCodec{operator.ProtobufContentType, proto.Marshal, proto.Unmarshal}
+	}
+	return operatorCodec{
+		contentType: operator.JSONContentType,
+		marshal:     func(m proto.Message) ([]byte, error) { return json.Marshal(m) },
+		unmarshal:   func(data []byte, m proto.Message) error { return json.Unmarshal(data, m) },
+	}
+}
+
+// request returns the request making the given call to the v1 operator API
+// at the given URL.
+func (c operatorCodec) request(u url.URL, t operator.CallType) (*http.Request, error) {
+	body, err := c.marshal(&operator.Call{Type: t})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", c.contentType)
+	req.Header.Set("Accept", c.contentType)
+	return req, nil
+}
+
 // Catches an attempt to load state.json from a mesos master
 // attempts can fail from down server or mesos master secondary
 // it also reloads from a different master if the master it attempted to
@@ -186,82 +394,321 @@ func hashString(s string) string {
 	return zbase32.EncodeToString(hash[:])[:5]
 }
 
-// attempt to translate the hostname into an IPv4 address. logs an error if IP
-// lookup fails. if an IP address cannot be found, returns the same hostname
-// that was given. upon success returns the IP address as a string.
-func hostToIP4(hostname string) (string, bool) {
-	ip := net.ParseIP(hostname)
-	if ip == nil {
-		t, err := net.ResolveIPAddr("ip4", hostname)
-		if err != nil {
-			logging.Error.Printf("cannot translate hostname %q into an ip4 address", hostname)
-			return hostname, false
+// attempt to translate the hostname into IP addresses of the families
+// enabled by the configured IPMode. logs an error if IP lookup fails. if no IP
+// address can be found, returns the same hostname that was given. upon
+// success returns the IP addresses as strings.
+func (rg *RecordGenerator) hostToIPs(hostname string) ([]string, bool) {
+	if ip := net.ParseIP(hostname); ip != nil {
+		return []string{ip.String()}, true
+	}
+	ips, err := rg.lookupIP(hostname)
+	if err != nil {
+		logging.Error.Printf("cannot translate hostname %q into an ip address", hostname)
+		return []string{hostname}, false
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if rg.ipModeAllows(ip) {
+			addrs = append(addrs, ip.String())
+		}
+	}
+	if len(addrs) == 0 {
+		logging.Error.Printf("cannot translate hostname %q into an %s address", hostname, rg.config.IPMode)
+		return []string{hostname}, false
+	}
+	return addrs, true
+}
+
+// lookupIP returns the IP addresses of the given hostname, looked up
+// beforehand by resolveHosts if possible.
+func (rg *RecordGenerator) lookupIP(hostname string) ([]net.IP, error) {
+	if r, ok := rg.resolved[hostname]; ok {
+		return r.ips, r.err
+	}
+	return rg.hostCache().LookupIP(hostname)
+}
+
+// hostCache returns the HostCache of the generator, or one which doesn't
+// cache anything if none is set.
+func (rg *RecordGenerator) hostCache() *HostCache {
+	if rg.hosts == nil {
+		rg.hosts = NewHostCache(0, 0)
+	}
+	return rg.hosts
+}
+
+// resolveHosts looks up the hostnames of all the frameworks and slaves of the
+// given state concurrently, so that slow lookups don't add up.
+func (rg *RecordGenerator) resolveHosts(sj state.State) {
+	seen := map[string]struct{}{}
+	var hosts []string
+	add := func(host string) {
+		if _, ok := seen[host]; !ok && host != "" && net.ParseIP(host) == nil {
+			seen[host] = struct{}{}
+			hosts = append(hosts, host)
+		}
+	}
+	for _, f := range sj.Frameworks {
+		host, _ := f.HostPort()
+		add(host)
+	}
+	for _, slave := range sj.Slaves {
+		if slave.PID.UPID != nil {
+			add(slave.PID.Host)
 		}
-		ip = t.IP
 	}
-	return ip.String(), true
+	if len(hosts) == 0 {
+		return
+	}
+
+	workers := rg.config.HostLookupWorkers
+	if workers < 1 {
+		workers = defaultHostLookupWorkers
+	}
+	start := time.Now()
+	rg.resolved = rg.hostCache().lookupAll(hosts, workers)
+	logging.VeryVerbose.Printf("resolved %d hostnames in %v", len(hosts), time.Since(start))
+}
+
+// ipModeAllows returns whether the family of the given IP is enabled by the
+// configured IPMode.
+func (rg *RecordGenerator) ipModeAllows(ip net.IP) bool {
+	switch rg.config.IPMode {
+	case IPv4Mode:
+		return ip.To4() != nil
+	case IPv6Mode:
+		return ip.To4() == nil
+	default:
+		return true
+	}
 }
 
 // InsertState transforms a StateJSON into RecordGenerator RRs
 func (rg *RecordGenerator) InsertState(sj state.State, domain, ns, listener string, masters, ipSources []string, spec labels.Func) error {
 
 	rg.SlaveIPs = map[string]string{}
-	rg.SRVs = rrs{}
-	rg.As = rrs{}
+	rg.slaveAddrs = map[string][]string{}
+	rg.slaveAttrs = map[string]state.Attributes{}
+	rg.taskTTLs = map[string]uint32{}
+	rg.unhealthy = map[string]struct{}{}
+	rg.LiveTasks = map[string]struct{}{}
+	rg.resolved = nil
+	rg.claims = claims{}
+	rg.owned = map[string]struct{}{}
+	rg.Records = NewRecordSet()
+	rg.Filtered = make(map[string]int, len(recordClasses))
+	for _, class := range recordClasses {
+		rg.Filtered[class] = 0
+	}
+	rg.resolveHosts(sj)
 	rg.frameworkRecords(sj, domain, spec)
 	rg.slaveRecords(sj, domain, spec)
 	rg.listenerRecord(listener, ns)
+	rg.staticRecords(domain)
 	rg.masterRecord(domain, masters, sj.Leader)
 	rg.taskRecords(sj, domain, spec, ipSources)
+	if rg.config.HealthPolicy == HealthyFirstPolicy {
+		rg.preferHealthy()
+	}
+	rg.resolveCollisions()
+	rg.dropShadowingAliases()
+
+	if len(rg.filters) > 0 {
+		logging.Verbose.Printf("filtered A and AAAA records by IP: %v", rg.Filtered)
+	}
 
 	return nil
 }
 
-// frameworkRecords injects A and SRV records into the generator store:
-//     frameworkname.domain.                 // resolves to IPs of each framework
-//     _framework._tcp.frameworkname.domain. // resolves to the driver port and IP of each framework
+// frameworkRecords injects A, AAAA and SRV records into the generator store:
+//
+//	frameworkname.domain.                          // resolves to IPs of each framework
+//	_framework._tcp.frameworkname.domain.          // resolves to the driver port and IP of each framework
+//	frameworkid.frameworks.domain.                 // resolves to IPs of the framework with the ID
+//	_framework._tcp.frameworkid.frameworks.domain. // resolves to the driver port and IP of the framework
+//
+// Frameworks sharing the same name are reported as collisions, their records
+// being resolved by the CollisionPolicy.
 func (rg *RecordGenerator) frameworkRecords(sj state.State, domain string, spec labels.Func) {
 	for _, f := range sj.Frameworks {
 		fname := labels.DomainFrag(f.Name, labels.Sep, spec)
+
+		// frameworks claim their names whether they get records or not, so
+		// that frameworks sharing a name are reported as collisions,
+		// along with the names of their tasks.
+		if f.ID != "" {
+			origin := Origin{FrameworkID: f.ID}
+			rg.claims.register(origin, App{FrameworkID: f.ID, Framework: f.Name})
+			if fname != "" {
+				rg.claims.claim(RR{Name: fname + "." + domain + ".", Origin: origin})
+			}
+		}
+
 		host, port := f.HostPort()
-		if address, ok := hostToIP4(host); ok {
-			a := fname + "." + domain + "."
-                        if !strings.HasPrefix(address, "172") {
-			    rg.insertRR(a, address, "A")
-                        }
-			if port != "" {
-				srv := net.JoinHostPort(a, port)
-				rg.insertRR("_framework._tcp."+a, srv, "SRV")
+		if addresses, ok := rg.hostToIPs(host); ok {
+			names := []string{fname + "." + domain + "."}
+			if id := frameworkIDName(f.ID); id != "" {
+				names = append(names, id+".frameworks."+domain+".")
+			}
+			origin := Origin{FrameworkID: f.ID}
+			for _, a := range names {
+				for _, address := range addresses {
+					rg.insertAddr(FrameworkClass, a, address, origin)
+				}
+				if port != "" {
+					rg.insertSRV(FrameworkClass, "_framework._tcp."+a, a, port, SRVOptions{}, origin)
+				}
 			}
 		}
 	}
 }
 
-// slaveRecords injects A and SRV records into the generator store:
-//     slave.domain.      // resolves to IPs of all slaves
-//     _slave._tc.domain. // resolves to the driver port and IP of all slaves
+// frameworkIDName returns the name of the framework with the given ID,
+// relative to the frameworks zone: a single label made of the whole ID,
+// lowercased, with dots and underscores mapped to hyphens and other invalid
+// characters dropped, e.g. "20140703-014514-3041283216-5050-5348-0001". Unlike
+// other labels, it isn't subject to the RFC 952 length and leading digit
+// rules, which would make IDs ambiguous. IDs not making a valid label get no
+// name.
+func frameworkIDName(id string) string {
+	name := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			return r - ('A' - 'a')
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r == '.' || r == '_':
+			return '-'
+		default:
+			return -1
+		}
+	}, id), "-")
+	if len(name) > 63 {
+		logging.Verbose.Printf("framework ID %q is too long to be a label; it gets no name", id)
+		return ""
+	}
+	return name
+}
+
+// frameworkZones returns the zones, relative to the domain, the tasks of the
+// given framework are published in besides its name: its ID under the
+// frameworks zone and each of its roles under its name, e.g.
+// "20160107-001256-134875658-5050-27524-0000.frameworks" and "marathon.prod".
+func frameworkZones(f state.Framework, fname string, spec labels.Func) []string {
+	var zones []string
+	if id := frameworkIDName(f.ID); id != "" {
+		zones = append(zones, id+".frameworks")
+	}
+	for _, role := range f.AllRoles() {
+		if r := spec(role); r != "" {
+			zones = append(zones, fname+"."+r)
+		}
+	}
+	return zones
+}
+
+// slaveRecords injects A, AAAA and SRV records into the generator store:
+//
+//	slave.domain.                      // resolves to IPs of all slaves
+//	_slave._tc.domain.                 // resolves to the driver port and IP of all slaves
+//	hostname.agents.domain.            // resolves to IPs of the slave with the hostname
+//	idtail.agents.domain.              // resolves to IPs of the slave with the ID tail
+//	_slave._tcp.hostname.agents.domain // resolves to the driver port and IP of the slave
+//	_slave._tcp.idtail.agents.domain   // resolves to the driver port and IP of the slave
+//	slave.name-value.domain.           // resolves to IPs of the slaves with the attribute value
+//
+// Only the attributes listed in AgentAttributes get names.
 func (rg *RecordGenerator) slaveRecords(sj state.State, domain string, spec labels.Func) {
 	for _, slave := range sj.Slaves {
-		address, ok := hostToIP4(slave.PID.Host)
+		addresses, ok := rg.hostToIPs(slave.PID.Host)
 		if ok {
 			a := "slave." + domain + "."
-                        if !strings.HasPrefix(address, "172") {
-			    rg.insertRR(a, address, "A")
-                        }
-			srv := net.JoinHostPort(a, slave.PID.Port)
-			rg.insertRR("_slave._tcp."+domain+".", srv, "SRV")
+			origin := Origin{AgentID: slave.ID}
+			names := agentNames(slave, spec)
+			// agent addresses map back to the agent's own name, preferably
+			// its hostname, rather than to the name all agents share.
+			ptr := a
+			if len(names) > 0 {
+				ptr = names[len(names)-1] + ".agents." + domain + "."
+			}
+			for _, address := range addresses {
+				rg.insertAddr(AgentClass, a, address, origin)
+				rg.insertPTR(AgentClass, address, ptr, origin)
+			}
+			rg.insertSRV(AgentClass, "_slave._tcp."+domain+".", a, slave.PID.Port, SRVOptions{}, origin)
+
+			for _, name := range names {
+				name += ".agents." + domain + "."
+				for _, address := range addresses {
+					rg.insertAddr(AgentClass, name, address, origin)
+				}
+				rg.insertSRV(AgentClass, "_slave._tcp."+name, name, slave.PID.Port, SRVOptions{}, origin)
+			}
+
+			for _, zone := range rg.attributeZones(slave.Attributes, spec) {
+				for _, address := range addresses {
+					rg.insertAddr(AgentClass, "slave."+zone+"."+domain+".", address, origin)
+				}
+			}
 		} else {
-			logging.VeryVerbose.Printf("string '%q' for slave with id %q is not a valid IP address", address, slave.ID)
-			address = labels.DomainFrag(address, labels.Sep, spec)
+			logging.VeryVerbose.Printf("string '%q' for slave with id %q is not a valid IP address", addresses[0], slave.ID)
+			addresses = []string{labels.DomainFrag(addresses[0], labels.Sep, spec)}
 		}
-		rg.SlaveIPs[slave.ID] = address
+		rg.SlaveIPs[slave.ID] = primaryAddr(addresses)
+		rg.slaveAddrs[slave.ID] = addresses
+		rg.slaveAttrs[slave.ID] = slave.Attributes
 	}
 }
 
+// attributeZones returns the "name-value" subdomains of the given slave
+// attributes listed in AgentAttributes, e.g. "rack-12" for a rack attribute
+// of 12. Attributes the slave doesn't have are skipped.
+func (rg *RecordGenerator) attributeZones(attrs state.Attributes, spec labels.Func) []string {
+	var zones []string
+	for _, name := range rg.config.AgentAttributes {
+		key, value := spec(name), spec(attrs[name])
+		if key != "" && value != "" {
+			zones = append(zones, key+"-"+value)
+		}
+	}
+	return zones
+}
+
+// agentNames returns the names, relative to the agents zone, of the given
+// slave: its ID tail and its hostname, unless that's empty or an IP address.
+func agentNames(slave state.Slave, spec labels.Func) []string {
+	var names []string
+	if tail := spec(slaveIDTail(slave.ID)); tail != "" {
+		names = append(names, tail)
+	}
+	if slave.Hostname != "" && net.ParseIP(slave.Hostname) == nil {
+		if host := labels.DomainFrag(slave.Hostname, labels.Sep, spec); host != "" {
+			names = append(names, host)
+		}
+	}
+	return names
+}
+
+// primaryAddr returns the first IPv4 address in the given list, falling back
+// to the first address if there's none.
+func primaryAddr(addrs []string) string {
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+			return addr
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs[0]
+	}
+	return ""
+}
+
 // masterRecord injects A and SRV records into the generator store:
-//     master.domain.  // resolves to IPs of all masters
-//     masterN.domain. // one IP address for each master
-//     leader.domain.  // one IP address for the leading master
+//
+//	master.domain.  // resolves to IPs of all masters
+//	masterN.domain. // one IP address for each master
+//	leader.domain.  // one IP address for the leading master
 //
 // The current func implementation makes an assumption about the order of masters:
 // it's the order in which you expect the enumerated masterN records to be created.
@@ -299,23 +746,20 @@ func (rg *RecordGenerator) masterRecord(domain string, masters []string, leader
 		return
 	}
 	arec := "leader." + domain + "."
-        if !strings.HasPrefix(ip, "172") {
-	    rg.insertRR(arec, ip, "A")
-        }
+	rg.insertAddr(MasterClass, arec, ip, Origin{})
 	arec = "master." + domain + "."
-        if !strings.HasPrefix(ip, "172") {
-	    rg.insertRR(arec, ip, "A")
-        }
+	rg.insertAddr(MasterClass, arec, ip, Origin{})
 
 	// SRV records
 	tcp := "_leader._tcp." + domain + "."
 	udp := "_leader._udp." + domain + "."
-	host := "leader." + domain + "." + ":" + port
-	rg.insertRR(tcp, host, "SRV")
-	rg.insertRR(udp, host, "SRV")
+	host := "leader." + domain + "."
+	rg.insertSRV(MasterClass, tcp, host, port, SRVOptions{}, Origin{})
+	rg.insertSRV(MasterClass, udp, host, port, SRVOptions{}, Origin{})
 
 	// if there is a list of masters, insert that as well
 	addedLeaderMasterN := false
+	seen := map[string]bool{}
 	idx := 0
 	for _, master := range masters {
 		masterIP, _, err := getProto(master)
@@ -326,14 +770,13 @@ func (rg *RecordGenerator) masterRecord(domain string, masters []string, leader
 
 		// A records (master and masterN)
 		if master != leaderAddress {
+			if seen[master] {
+				// duplicate master?!
+				continue
+			}
+			seen[master] = true
 			arec := "master." + domain + "."
-                        if !strings.HasPrefix(masterIP, "172") {
-			    added := rg.insertRR(arec, masterIP, "A")
-			    if !added {
-			    	// duplicate master?!
-			    	continue
-			    }
-                        }
+			rg.insertAddr(MasterClass, arec, masterIP, Origin{})
 		}
 
 		if master == leaderAddress && addedLeaderMasterN {
@@ -342,9 +785,8 @@ func (rg *RecordGenerator) masterRecord(domain string, masters []string, leader
 		}
 
 		arec := "master" + strconv.Itoa(idx) + "." + domain + "."
-                if !strings.HasPrefix(masterIP, "172") {
-		    rg.insertRR(arec, masterIP, "A")
-                }
+		rg.insertAddr(MasterClass, arec, masterIP, Origin{})
+		rg.insertPTR(MasterClass, masterIP, arec, Origin{})
 		idx++
 
 		if master == leaderAddress {
@@ -358,9 +800,8 @@ func (rg *RecordGenerator) masterRecord(domain string, masters []string, leader
 			logging.Error.Printf("warning: leader %q is not in master list", leader)
 		}
 		arec = "master" + strconv.Itoa(idx) + "." + domain + "."
-                if !strings.HasPrefix(ip, "172") {
-		    rg.insertRR(arec, ip, "A")
-                }
+		rg.insertAddr(MasterClass, arec, ip, Origin{})
+		rg.insertPTR(MasterClass, ip, arec, Origin{})
 	}
 }
 
@@ -369,105 +810,611 @@ func (rg *RecordGenerator) listenerRecord(listener string, ns string) {
 	if listener == "0.0.0.0" {
 		rg.setFromLocal(listener, ns)
 	} else if listener == "127.0.0.1" {
-		rg.insertRR(ns, "127.0.0.1", "A")
+		rg.insertAddr(ListenerClass, ns, "127.0.0.1", Origin{})
 	} else {
-		rg.insertRR(ns, listener, "A")
+		rg.insertAddr(ListenerClass, ns, listener, Origin{})
 	}
 }
 
+// taskContext holds what the records of a task are made of.
+type taskContext struct {
+	task                      *state.Task
+	framework                 *state.Framework
+	fname                     string
+	taskName, taskID, slaveID string
+	taskIPs, slaveIPs         []string
+	srv                       SRVOptions
+	origin                    Origin
+	spec                      labels.Func
+}
+
+// canonical returns the canonical name of the task, relative to the domain.
+func (ctx *taskContext) canonical() string {
+	return ctx.taskName + "-" + ctx.taskID + "-" + ctx.slaveID + "." + ctx.fname
+}
+
 func (rg *RecordGenerator) taskRecords(sj state.State, domain string, spec labels.Func, ipSources []string) {
-	for _, f := range sj.Frameworks {
+	maxResource := rg.maxTaskResource(sj, rg.config.SRVWeightResource)
+	templates := rg.templates
+	if templates == nil {
+		templates = parseNameTemplates(nil)
+	}
+	for i := range sj.Frameworks {
+		f := &sj.Frameworks[i]
 		fname := labels.DomainFrag(f.Name, labels.Sep, spec)
+		fzones := frameworkZones(*f, fname, spec)
 
 		// insert taks records
-		tail := "." + domain + "."
 		for _, task := range f.Tasks {
 			var ok bool
 			task.SlaveIP, ok = rg.SlaveIPs[task.SlaveID]
 
-			// skip not running or not discoverable tasks
-			if !ok || (task.State != "TASK_RUNNING") {
+			// skip unpublished or not discoverable tasks
+			if !ok || !rg.publishedState(task.State) {
+				continue
+			}
+			rg.LiveTasks[task.ID] = struct{}{}
+			if !rg.checkHealth(&task) {
 				continue
 			}
 
-			// define context
-			ctx := struct{ taskName, taskID, slaveID, taskIP, slaveIP string }{
-				spec(task.Name),
-				hashString(task.ID),
-				slaveIDTail(task.SlaveID),
-				task.IP(ipSources...),
-				task.SlaveIP,
+			ctx := &taskContext{
+				task:      &task,
+				framework: f,
+				fname:     fname,
+				taskName:  spec(task.Name),
+				taskID:    hashString(task.ID),
+				slaveID:   slaveIDTail(task.SlaveID),
+				taskIPs:   rg.taskIPs(&task, ipSources),
+				slaveIPs:  rg.slaveAddrs[task.SlaveID],
+				srv:       rg.srvOptions(&task, maxResource),
+				origin:    Origin{TaskID: task.ID, FrameworkID: task.FrameworkID, AgentID: task.SlaveID},
+				spec:      spec,
 			}
 
 			// use DiscoveryInfo name if defined instead of task name
 			if task.HasDiscoveryInfo() {
 				ctx.taskName = task.DiscoveryInfo.Name
 			}
+			rg.registerTask(ctx)
+
+			// insert records in every zone the task is visible in, and
+			// PTR records in the first one, the domain, only: each
+			// address maps back to a single name.
+			tails := rg.taskZones(&task, domain)
+			if len(tails) > 0 {
+				rg.canonicalPTRRecords(ctx, tails[0])
+			}
+			for _, tail := range tails {
+				rg.canonicalRecords(ctx, tail)
+				names := rg.templateRecords(ctx, templates, tail)
+				rg.networkRecords(ctx, tail)
+				rg.attributeRecords(ctx, tail)
+				rg.taskTXTRecords(ctx, names, tail)
+				rg.taskSRVRecords(ctx, tail)
+				rg.frameworkZoneRecords(ctx, fzones, tail)
+				if task.HasDiscoveryInfo() {
+					rg.discoveryPortRecords(ctx, tail)
+					rg.discoveryZoneRecords(ctx, tail)
+				}
+			}
 
-			// insert canonical A records
-			canonical := ctx.taskName + "-" + ctx.taskID + "-" + ctx.slaveID + "." + fname
-			arec := ctx.taskName + "." + fname
-			shortArec := ctx.taskName
-
-                        if !strings.HasPrefix(ctx.taskIP, "172") {
-			    rg.insertRR(arec+tail, ctx.taskIP, "A")
-			    rg.insertRR(shortArec+tail, ctx.taskIP, "A")
-			    rg.insertRR(canonical+tail, ctx.taskIP, "A")
-                        }
-                        if !strings.HasPrefix(ctx.slaveIP, "172") {
-			    rg.insertRR(shortArec+".slave"+tail, ctx.slaveIP, "A")
-			    rg.insertRR(arec+".slave"+tail, ctx.slaveIP, "A")
-			    rg.insertRR(canonical+".slave"+tail, ctx.slaveIP, "A")
-                        }
-
-			// Add RFC 2782 SRV records
-			slaveHost := canonical + ".slave" + tail
-			tcpName := "_" + ctx.taskName + "._tcp." + fname
-			udpName := "_" + ctx.taskName + "._udp." + fname
-                        shortTcpName := "_" + ctx.taskName + "._tcp"
-                        shortUdpName := "_" + ctx.taskName + "._udp"
-			for _, port := range task.Ports() {
-				slaveTarget := slaveHost + ":" + port
-
-				if !task.HasDiscoveryInfo() {
-                                        rg.insertRR(shortTcpName+tail, slaveTarget, "SRV")
-                                        rg.insertRR(shortUdpName+tail, slaveTarget, "SRV")
-					rg.insertRR(tcpName+tail, slaveTarget, "SRV")
-					rg.insertRR(udpName+tail, slaveTarget, "SRV")
+			// insert the aliases of the task once all its records are in
+			if aliases := taskAliases(&task, domain, spec); len(aliases) > 0 {
+				target := rg.aliasTarget(ctx, templates)
+				for _, tail := range tails {
+					rg.insertAliases(aliases, target, ctx.taskName, fname, tail, ctx.origin)
 				}
+			}
+		}
+	}
+}
+
+// checkHealth reports whether the given task is published as far as its
+// health checks are concerned, marking it unhealthy if it's failing them.
+func (rg *RecordGenerator) checkHealth(task *state.Task) bool {
+	if healthy, known := task.Healthy(); known && !healthy {
+		if rg.config.HealthPolicy == HealthyPolicy {
+			logging.VeryVerbose.Printf("skipped unhealthy task %q", task.ID)
+			return false
+		}
+		rg.unhealthy[task.ID] = struct{}{}
+	}
+	return true
+}
+
+// registerTask registers the app and the TTL of the records of a task.
+func (rg *RecordGenerator) registerTask(ctx *taskContext) {
+	task := ctx.task
+	if task.ID != "" {
+		app := App{FrameworkID: ctx.framework.ID, Framework: ctx.framework.Name, Task: task.Name}
+		if task.HasDiscoveryInfo() {
+			app.Task = task.DiscoveryInfo.Name
+		}
+		rg.claims.register(ctx.origin, app)
+	}
+	if ttl, ok := taskTTL(task); ok {
+		rg.taskTTLs[task.ID] = ttl
+	}
+}
+
+// canonicalRecords inserts the canonical A records of a task, and the A
+// records of its agent under the canonical name.
+func (rg *RecordGenerator) canonicalRecords(ctx *taskContext, tail string) {
+	canonical := ctx.canonical()
+	for _, ip := range ctx.taskIPs {
+		rg.insertAddr(TaskClass, canonical+tail, ip, ctx.origin)
+	}
+	for _, ip := range ctx.slaveIPs {
+		rg.insertAddr(TaskClass, canonical+".slave"+tail, ip, ctx.origin)
+	}
+}
 
-				rg.insertRR(tcpName+".slave"+tail, slaveTarget, "SRV")
-				rg.insertRR(udpName+".slave"+tail, slaveTarget, "SRV")
-                                rg.insertRR(shortTcpName+".slave"+tail, slaveTarget, "SRV")
-                                rg.insertRR(shortUdpName+".slave"+tail, slaveTarget, "SRV")
+// canonicalPTRRecords inserts the PTR records mapping the addresses of a task
+// back to its canonical name in the zone of the given tail. Agent addresses
+// are mapped back to the agent instead.
+func (rg *RecordGenerator) canonicalPTRRecords(ctx *taskContext, tail string) {
+	canonical := ctx.canonical()
+	for _, ip := range ctx.taskIPs {
+		if !contains(ctx.slaveIPs, ip) {
+			rg.insertPTR(TaskClass, ip, canonical+tail, ctx.origin)
+		}
+	}
+}
+
+// templateRecords inserts the A records of the configured name templates,
+// returning the names of those of task addresses.
+func (rg *RecordGenerator) templateRecords(ctx *taskContext, templates []nameTemplate, tail string) []string {
+	task := ctx.task
+	vars := taskTemplateVars(task, ctx.taskName, ctx.fname, ctx.taskID, ctx.slaveID, rg.slaveAttrs[task.SlaveID], ctx.spec)
+	var names []string
+	for _, t := range templates {
+		name, ok := t.expand(vars)
+		if !ok {
+			continue
+		}
+		ips := ctx.slaveIPs
+		if t.target == TaskTarget {
+			ips = ctx.taskIPs
+			names = append(names, name+tail)
+		}
+		for _, ip := range ips {
+			rg.insertAddr(TaskClass, name+tail, ip, ctx.origin)
+		}
+	}
+	return names
+}
+
+// aliasTarget returns the name aliases of a task point at, relative to the
+// domain: the first name of its addresses generated by the given templates,
+// or its canonical name if none applies.
+func (rg *RecordGenerator) aliasTarget(ctx *taskContext, templates []nameTemplate) string {
+	task := ctx.task
+	vars := taskTemplateVars(task, ctx.taskName, ctx.fname, ctx.taskID, ctx.slaveID, rg.slaveAttrs[task.SlaveID], ctx.spec)
+	for _, t := range templates {
+		if t.target != TaskTarget {
+			continue
+		}
+		if name, ok := t.expand(vars); ok {
+			return name
+		}
+	}
+	return ctx.canonical()
+}
+
+// networkRecords inserts the A records of the addresses of a task in each of
+// its named networks, e.g. task.framework.network.domain
+func (rg *RecordGenerator) networkRecords(ctx *taskContext, tail string) {
+	for network, ips := range ctx.task.NetworkIPs() {
+		zone := labels.DomainFrag(network, labels.Sep, ctx.spec)
+		if zone == "" {
+			continue
+		}
+		for _, ip := range ips {
+			rg.insertAddr(TaskClass, ctx.taskName+"."+ctx.fname+"."+zone+tail, ip.String(), ctx.origin)
+		}
+	}
+}
+
+// attributeRecords inserts the A records of a task scoped by the attributes
+// of its agent.
+func (rg *RecordGenerator) attributeRecords(ctx *taskContext, tail string) {
+	for _, zone := range rg.attributeZones(rg.slaveAttrs[ctx.task.SlaveID], ctx.spec) {
+		for _, ip := range ctx.taskIPs {
+			rg.insertAddr(TaskClass, ctx.taskName+"."+ctx.fname+"."+zone+tail, ip, ctx.origin)
+		}
+	}
+}
+
+// taskTXTRecords inserts the TXT records of the allowed labels of a task under
+// the given names of its addresses, plus its metadata under its canonical name.
+func (rg *RecordGenerator) taskTXTRecords(ctx *taskContext, names []string, tail string) {
+	task, canonical := ctx.task, ctx.canonical()+tail
+	for _, key := range rg.config.TXTLabels {
+		if value, ok := task.LabelValue(key); ok {
+			txt := txtString(key, value)
+			for _, name := range names {
+				rg.insertTXT(TaskClass, name, txt, ctx.origin)
 			}
+			rg.insertTXT(TaskClass, canonical, txt, ctx.origin)
+		}
+	}
+	rg.insertTXT(TaskClass, canonical, txtString("task_id", task.ID), ctx.origin)
+	rg.insertTXT(TaskClass, canonical, txtString("framework", ctx.framework.Name), ctx.origin)
+	rg.insertTXT(TaskClass, canonical, txtString("agent_id", task.SlaveID), ctx.origin)
+}
 
-			if !task.HasDiscoveryInfo() {
-				continue
+// taskSRVRecords inserts the RFC 2782 SRV records of the host ports of a task.
+func (rg *RecordGenerator) taskSRVRecords(ctx *taskContext, tail string) {
+	slaveHost := ctx.canonical() + ".slave" + tail
+	tcpName := "_" + ctx.taskName + "._tcp." + ctx.fname
+	udpName := "_" + ctx.taskName + "._udp." + ctx.fname
+	shortTcpName := "_" + ctx.taskName + "._tcp"
+	shortUdpName := "_" + ctx.taskName + "._udp"
+	for _, port := range ctx.task.Ports() {
+		if !ctx.task.HasDiscoveryInfo() {
+			rg.insertSRV(TaskClass, shortTcpName+tail, slaveHost, port, ctx.srv, ctx.origin)
+			rg.insertSRV(TaskClass, shortUdpName+tail, slaveHost, port, ctx.srv, ctx.origin)
+			rg.insertSRV(TaskClass, tcpName+tail, slaveHost, port, ctx.srv, ctx.origin)
+			rg.insertSRV(TaskClass, udpName+tail, slaveHost, port, ctx.srv, ctx.origin)
+		}
+
+		rg.insertSRV(TaskClass, tcpName+".slave"+tail, slaveHost, port, ctx.srv, ctx.origin)
+		rg.insertSRV(TaskClass, udpName+".slave"+tail, slaveHost, port, ctx.srv, ctx.origin)
+		rg.insertSRV(TaskClass, shortTcpName+".slave"+tail, slaveHost, port, ctx.srv, ctx.origin)
+		rg.insertSRV(TaskClass, shortUdpName+".slave"+tail, slaveHost, port, ctx.srv, ctx.origin)
+	}
+}
+
+// frameworkZoneRecords inserts the A and SRV records of a task in the given
+// framework ID and role zones.
+func (rg *RecordGenerator) frameworkZoneRecords(ctx *taskContext, fzones []string, tail string) {
+	slaveHost := ctx.canonical() + ".slave" + tail
+	for _, zone := range fzones {
+		for _, ip := range ctx.taskIPs {
+			rg.insertAddr(TaskClass, ctx.taskName+"."+zone+tail, ip, ctx.origin)
+		}
+		for _, port := range ctx.task.Ports() {
+			rg.insertSRV(TaskClass, "_"+ctx.taskName+"._tcp."+zone+tail, slaveHost, port, ctx.srv, ctx.origin)
+			rg.insertSRV(TaskClass, "_"+ctx.taskName+"._udp."+zone+tail, slaveHost, port, ctx.srv, ctx.origin)
+		}
+	}
+}
+
+// discoveryPortRecords inserts the SRV records of the DiscoveryInfo ports of a
+// task, under their protocol if defined or both tcp and udp otherwise.
+func (rg *RecordGenerator) discoveryPortRecords(ctx *taskContext, tail string) {
+	target := ctx.canonical() + tail
+	tcpName := "_" + ctx.taskName + "._tcp." + ctx.fname
+	udpName := "_" + ctx.taskName + "._udp." + ctx.fname
+	shortTcpName := "_" + ctx.taskName + "._tcp"
+	shortUdpName := "_" + ctx.taskName + "._udp"
+	for _, port := range ctx.task.DiscoveryInfo.Ports.DiscoveryPorts {
+		p := strconv.Itoa(port.Number)
+
+		// use protocol if defined, fallback to tcp+udp
+		proto := ctx.spec(port.Protocol)
+		if proto != "" {
+			name := "_" + ctx.taskName + "._" + proto + "." + ctx.fname
+			shortName := "_" + ctx.taskName + "._" + proto
+			rg.insertSRV(TaskClass, shortName+tail, target, p, ctx.srv, ctx.origin)
+			rg.insertSRV(TaskClass, name+tail, target, p, ctx.srv, ctx.origin)
+		} else {
+			rg.insertSRV(TaskClass, shortTcpName+tail, target, p, ctx.srv, ctx.origin)
+			rg.insertSRV(TaskClass, shortUdpName+tail, target, p, ctx.srv, ctx.origin)
+			rg.insertSRV(TaskClass, tcpName+tail, target, p, ctx.srv, ctx.origin)
+			rg.insertSRV(TaskClass, udpName+tail, target, p, ctx.srv, ctx.origin)
+		}
+
+		// named ports get their own SRV records, e.g.
+		// _http._task._tcp.framework.domain.
+		portName := ctx.spec(port.Name)
+		if portName == "" {
+			continue
+		}
+		protos := []string{"tcp", "udp"}
+		if proto != "" {
+			protos = []string{proto}
+		}
+		for _, proto := range protos {
+			shortName := "_" + portName + "._" + ctx.taskName + "._" + proto
+			rg.insertSRV(TaskClass, shortName+tail, target, p, ctx.srv, ctx.origin)
+			rg.insertSRV(TaskClass, shortName+"."+ctx.fname+tail, target, p, ctx.srv, ctx.origin)
+		}
+	}
+}
+
+// discoveryZoneRecords inserts the A and SRV records of a task scoped by its
+// DiscoveryInfo fields.
+func (rg *RecordGenerator) discoveryZoneRecords(ctx *taskContext, tail string) {
+	target := ctx.canonical() + tail
+	for _, z := range rg.discoveryZones(ctx.task, ctx.taskName, ctx.fname, ctx.spec) {
+		for _, ip := range ctx.taskIPs {
+			rg.insertAddr(TaskClass, z.arec+tail, ip, ctx.origin)
+		}
+		for _, port := range ctx.task.DiscoveryInfo.Ports.DiscoveryPorts {
+			p := strconv.Itoa(port.Number)
+			protos := []string{"tcp", "udp"}
+			if proto := ctx.spec(port.Protocol); proto != "" {
+				protos = []string{proto}
+			}
+			for _, proto := range protos {
+				name := "_" + ctx.taskName + "._" + proto + "." + z.srvDomain
+				rg.insertSRV(TaskClass, name+tail, target, p, ctx.srv, ctx.origin)
 			}
+		}
+	}
+}
 
-			for _, port := range task.DiscoveryInfo.Ports.DiscoveryPorts {
-				target := canonical + tail + ":" + strconv.Itoa(port.Number)
-
-				// use protocol if defined, fallback to tcp+udp
-				proto := spec(port.Protocol)
-				if proto != "" {
-					name := "_" + ctx.taskName + "._" + proto + "." + fname
-					shortName := "_" + ctx.taskName + "._" + proto
-                                        rg.insertRR(shortName+tail, target, "SRV")
-					rg.insertRR(name+tail, target, "SRV")
-				} else {
-					rg.insertRR(shortTcpName+tail, target, "SRV")
-					rg.insertRR(shortUdpName+tail, target, "SRV")
-                                        rg.insertRR(tcpName+tail, target, "SRV")
-                                        rg.insertRR(udpName+tail, target, "SRV")
+// preferHealthy drops the A, AAAA and SRV records of unhealthy tasks from
+// names which also have records of healthy tasks, so that unhealthy tasks are
+// only answered for when there's nothing better.
+func (rg *RecordGenerator) preferHealthy() {
+	if len(rg.unhealthy) == 0 {
+		return
+	}
+	isUnhealthy := func(rr RR) bool {
+		_, ok := rg.unhealthy[rr.Origin.TaskID]
+		return ok && rr.Class == TaskClass
+	}
+	rs, dropped := NewRecordSet(), 0
+	for k, rrs := range rg.Records.rrs {
+		healthy := 0
+		for _, rr := range rrs {
+			if !isUnhealthy(rr) {
+				healthy++
+			}
+		}
+		for _, rr := range rrs {
+			switch k.rtype {
+			case dns.TypeA, dns.TypeAAAA, dns.TypeSRV:
+				if healthy > 0 && isUnhealthy(rr) {
+					dropped++
+					continue
 				}
 			}
+			rs.insert(rr)
+		}
+	}
+	rg.Records = rs
+	logging.Verbose.Printf("dropped %d records of %d unhealthy tasks", dropped, len(rg.unhealthy))
+}
+
+// taskZones returns the tails ("." + zone + ".") of the zones the given task
+// is published in. Unless EnforceVisibility is set, that's the given domain
+// regardless of the task's DiscoveryInfo visibility. Otherwise FRAMEWORK
+// visible tasks aren't published at all and EXTERNAL visible ones are also
+// published in the ExternalDomain, if any.
+func (rg *RecordGenerator) taskZones(task *state.Task, domain string) []string {
+	cluster := "." + domain + "."
+	if !rg.config.EnforceVisibility {
+		return []string{cluster}
+	}
+	switch strings.ToUpper(task.DiscoveryInfo.Visibilty) {
+	case FrameworkVisibility:
+		logging.VeryVerbose.Printf("skipped framework visible task %q", task.ID)
+		return nil
+	case ExternalVisibility:
+		if ext := rg.config.ExternalDomain; ext != "" {
+			return []string{cluster, "." + ext + "."}
+		}
+	}
+	return []string{cluster}
+}
+
+// discoveryZone holds the A record name and the SRV domain, relative to the
+// domain, of a task in a zone scoped by one of its DiscoveryInfo fields.
+type discoveryZone struct {
+	arec, srvDomain string
+}
+
+// discoveryZones returns the zones of the given task scoped by the
+// DiscoveryInfo fields listed in DiscoveryZones, e.g. for a task "web" of
+// framework "marathon" with environment "prod" and version "2":
+//
+//	web.prod.marathon and _web._tcp.prod.marathon
+//	v2.web.marathon   and _web._tcp.v2.marathon
+//
+// Fields the task doesn't define are skipped.
+func (rg *RecordGenerator) discoveryZones(task *state.Task, taskName, fname string, spec labels.Func) []discoveryZone {
+	var zones []discoveryZone
+	for _, field := range rg.config.DiscoveryZones {
+		var sub string
+		switch field {
+		case "environment":
+			sub = spec(task.DiscoveryInfo.Environment)
+		case "location":
+			sub = spec(task.DiscoveryInfo.Location)
+		case "version":
+			if v := task.DiscoveryInfo.Version; v != "" {
+				if !strings.HasPrefix(v, "v") {
+					v = "v" + v
+				}
+				sub = spec(strings.Replace(v, ".", "-", -1))
+			}
+		}
+		if sub == "" {
+			continue
+		}
+		z := discoveryZone{taskName + "." + sub + "." + fname, sub + "." + fname}
+		if field == "version" {
+			z.arec = sub + "." + taskName + "." + fname
+		}
+		zones = append(zones, z)
+	}
+	return zones
+}
+
+// taskTemplateVars returns the values of the NameTemplate placeholders of the
+// given task running on a slave with the given attributes, mangled by the
+// given spec.
+func taskTemplateVars(task *state.Task, taskName, fname, taskID, slaveID string, attrs state.Attributes, spec labels.Func) templateVars {
+	return func(kind, arg string) (string, bool) {
+		switch kind {
+		case "name":
+			return taskName, true
+		case "framework":
+			return fname, true
+		case "id":
+			return taskID, true
+		case "agent":
+			return slaveID, true
+		case "label":
+			v, ok := task.LabelValue(arg)
+			return labels.DomainFrag(v, labels.Sep, spec), ok
+		case "attribute":
+			v, ok := attrs[arg]
+			return labels.DomainFrag(v, labels.Sep, spec), ok
+		case "discovery":
+			var v string
+			switch arg {
+			case "name":
+				v = task.DiscoveryInfo.Name
+			case "version":
+				v = task.DiscoveryInfo.Version
+			case "location":
+				v = task.DiscoveryInfo.Location
+			case "environment":
+				v = task.DiscoveryInfo.Environment
+			}
+			return labels.DomainFrag(v, labels.Sep, spec), v != ""
+		default:
+			return "", false
+		}
+	}
+}
+
+// taskIPs returns the IPv4 and IPv6 addresses of the given task for the
+// families enabled by the configured IPMode. The addresses of each family are
+// all taken from the first of the given sources, in priority order, that
+// yields any, so that multi-homed tasks get all their addresses. The "host"
+// source yields all the addresses of the slave the task runs on.
+func (rg *RecordGenerator) taskIPs(task *state.Task, srcs []string) []string {
+	var v4, v6 []string
+	for _, src := range srcs {
+		var addrs []string
+		if src == "host" {
+			addrs = rg.slaveAddrs[task.SlaveID]
+		} else {
+			for _, ip := range task.IPs(src) {
+				addrs = append(addrs, ip.String())
+			}
+		}
+		var srcV4, srcV6 []string
+		for _, addr := range addrs {
+			ip := net.ParseIP(addr)
+			switch {
+			case ip == nil || !rg.ipModeAllows(ip):
+			case ip.To4() != nil:
+				srcV4 = append(srcV4, addr)
+			default:
+				srcV6 = append(srcV6, addr)
+			}
+		}
+		if len(v4) == 0 {
+			v4 = srcV4
+		}
+		if len(v6) == 0 {
+			v6 = srcV6
+		}
+	}
+	return append(v4, v6...)
+}
+
+// publishedState returns whether tasks in the given state get records: those
+// listed in TaskStates, or only running ones if none are.
+func (rg *RecordGenerator) publishedState(st string) bool {
+	if len(rg.config.TaskStates) == 0 {
+		return st == "TASK_RUNNING"
+	}
+	return contains(rg.config.TaskStates, st)
+}
+
+// maxTaskResource returns the largest value of the given scalar resource
+// among all published tasks, or zero if no resource is given.
+func (rg *RecordGenerator) maxTaskResource(sj state.State, resource string) float64 {
+	var max float64
+	if resource == "" {
+		return max
+	}
+	for _, f := range sj.Frameworks {
+		for _, task := range f.Tasks {
+			if v := task.Resources.Scalar(resource); rg.publishedState(task.State) && v > max {
+				max = v
+			}
+		}
+	}
+	return max
+}
+
+// srvOptions returns the priority and weight of the SRV records of the given
+// task. They're read from the SRVPriorityLabel and SRVWeightLabel labels. If
+// there's no weight label and a SRVWeightResource is configured, the weight
+// is proportional to the task's share of the given maximum of that resource,
+// from 1 to maxResourceWeight.
+func (rg *RecordGenerator) srvOptions(task *state.Task, maxResource float64) SRVOptions {
+	var opts SRVOptions
+	if v, ok := task.LabelValue(SRVPriorityLabel); ok {
+		if p, err := strconv.ParseUint(v, 10, 16); err == nil {
+			opts.Priority = uint16(p)
+		} else {
+			logging.Verbose.Printf("invalid SRV priority %q of task %q", v, task.ID)
+		}
+	}
+	if v, ok := task.LabelValue(SRVWeightLabel); ok {
+		if w, err := strconv.ParseUint(v, 10, 16); err == nil {
+			opts.Weight = uint16(w)
+			return opts
+		}
+		logging.Verbose.Printf("invalid SRV weight %q of task %q", v, task.ID)
+	}
+	if maxResource > 0 {
+		share := task.Resources.Scalar(rg.config.SRVWeightResource) / maxResource
+		opts.Weight = uint16(math.Max(1, math.Floor(share*maxResourceWeight+0.5)))
+	}
+	return opts
+}
+
+// taskTTL returns the TTL of the records of the given task set by its
+// TTLLabel label, if any.
+func taskTTL(task *state.Task) (uint32, bool) {
+	v, ok := task.LabelValue(TTLLabel)
+	if !ok {
+		return 0, false
+	}
+	ttl, err := strconv.ParseUint(v, 10, 32)
+	if err != nil || ttl == 0 {
+		logging.Verbose.Printf("invalid TTL %q of task %q", v, task.ID)
+		return 0, false
+	}
+	return uint32(ttl), true
+}
+
+// staticRecords inserts the records of the configured StaticFiles. Records
+// outside of the given domain and NS records of the domain itself, which
+// mesos-dns is authoritative for, are skipped.
+func (rg *RecordGenerator) staticRecords(domain string) {
+	if len(rg.config.StaticFiles) == 0 {
+		return
+	}
+	if rg.static == nil {
+		rg.static = NewStaticFiles()
+	}
+	zone := strings.ToLower(domain) + "."
+	for _, rr := range rg.static.Records(rg.config.StaticFiles, zone) {
+		if rr.Name != zone && !strings.HasSuffix(rr.Name, "."+zone) {
+			logging.Verbose.Printf("skipped static record %s: outside of domain %q", rr, domain)
+			continue
 		}
+		if rr.Type == dns.TypeNS && rr.Name == zone {
+			logging.Verbose.Printf("skipped static record %s: domain can't be delegated", rr)
+			continue
+		}
+		rg.insertRR(rr)
 	}
 }
 
-// A records for each local interface
+// A and AAAA records for each local interface
 // If this causes problems you should explicitly set the
 // listener address in config.json
 func (rg *RecordGenerator) setFromLocal(host string, ns string) {
@@ -494,73 +1441,153 @@ func (rg *RecordGenerator) setFromLocal(host string, ns string) {
 				ip = v.IP
 			}
 
-			if ip == nil || ip.IsLoopback() {
-				continue
-			}
-
-			ip = ip.To4()
-			if ip == nil {
+			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
 				continue
 			}
-			
-			if strings.HasPrefix(ip.String(), "172") {
-                                continue
-                        }
 
-			rg.insertRR(ns, ip.String(), "A")
+			rg.insertAddr(ListenerClass, ns, ip.String(), Origin{})
 		}
 	}
 }
 
-func (rg *RecordGenerator) exists(name, host, rtype string) bool {
-	if rtype == "A" {
-		if val, ok := rg.As[name]; ok {
-			// check if A record already exists
-			// identical tasks on same slave
-			for _, b := range val {
-				if b == host {
-					return true
-				}
-			}
+// insertAddr adds an A or AAAA record, depending on the address family of
+// host, for the given name/host pair. hosts which aren't IP addresses are
+// skipped, as are addresses of families disabled by the IPMode. Those
+// rejected by the IP filters of the given record class are counted in
+// Filtered. returns true if added, false otherwise.
+func (rg *RecordGenerator) insertAddr(class, name, host string, origin Origin) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		if host != "" {
+			logging.VeryVerbose.Printf("skipped %s record %s: %q isn't an IP address", class, name, host)
 		}
-	} else {
-		if val, ok := rg.SRVs[name]; ok {
-			// check if SRV record already exists
-			for _, b := range val {
-				if b == host {
-					return true
-				}
-			}
+		return false
+	}
+	if !rg.ipModeAllows(ip) {
+		return false
+	}
+	rr := RR{Name: name, Type: dns.TypeA, IP: ip.To4(), Class: class, Origin: origin}
+	if rr.IP == nil {
+		rr.Type, rr.IP = dns.TypeAAAA, ip
+	}
+	if !rg.filters.allowed(class, host) {
+		logging.VeryVerbose.Printf("filtered %s %s record %s: %s", class, dns.TypeToString[rr.Type], name, host)
+		if rg.Filtered != nil {
+			rg.Filtered[class]++
 		}
+		return false
 	}
-	return false
+	return rg.insertRR(rr)
 }
 
-// insertRR adds a record to the appropriate record map for the given name/host pair,
-// but only if the pair is unique. returns true if added, false otherwise.
-// TODO(???): REFACTOR when storage is updated
-func (rg *RecordGenerator) insertRR(name, host, rtype string) bool {
-	if host == "" || rg.exists(name, host, rtype) {
+// insertSRV adds a SRV record for the given name pointing to target:port
+// with the given priority and weight. returns true if added, false otherwise.
+func (rg *RecordGenerator) insertSRV(class, name, target, port string, opts SRVOptions, origin Origin) bool {
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		logging.VeryVerbose.Printf("skipped %s SRV record %s: invalid port %q", class, name, port)
 		return false
 	}
+	return rg.insertRR(RR{
+		Name:     name,
+		Type:     dns.TypeSRV,
+		Target:   target,
+		Port:     uint16(p),
+		Priority: opts.Priority,
+		Weight:   opts.Weight,
+		Class:    class,
+		Origin:   origin,
+	})
+}
 
-	logging.VeryVerbose.Println("[" + rtype + "]\t" + name + ": " + host)
+// insertPTR adds a PTR record mapping the given address back to name, unless
+// the address isn't published for the given record class. returns true if
+// added, false otherwise.
+func (rg *RecordGenerator) insertPTR(class, addr, name string, origin Origin) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil || !rg.ipModeAllows(ip) || !rg.filters.allowed(class, addr) {
+		return false
+	}
+	rev, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return false
+	}
+	return rg.insertRR(RR{Name: rev, Type: dns.TypePTR, Target: name, Class: class, Origin: origin})
+}
 
-	if rtype == "A" {
-		val := rg.As[name]
-		rg.As[name] = append(val, host)
-	} else {
-		val := rg.SRVs[name]
-		rg.SRVs[name] = append(val, host)
+// insertTXT adds a TXT record holding the given text for the given name.
+// returns true if added, false otherwise.
+func (rg *RecordGenerator) insertTXT(class, name, txt string, origin Origin) bool {
+	return rg.insertRR(RR{Name: name, Type: dns.TypeTXT, Text: txt, Class: class, Origin: origin})
+}
+
+// insertRR adds a record to the record set, but only if its name, type and
+// value are unique. Records without a TTL get the one set by their task's
+// label or configured for their record class, if any. Records of names
+// claimed by another app first are dropped if the CollisionPolicy says so.
+// returns true if added, false otherwise.
+func (rg *RecordGenerator) insertRR(rr RR) bool {
+	if rg.Records == nil {
+		rg.Records = NewRecordSet()
+	}
+	if rr.TTL == 0 {
+		rr.TTL = rg.recordTTL(rr)
 	}
+	if app, ok := rg.claims.claim(rr); ok && rg.config.CollisionPolicy == FirstWinsPolicy && rg.claims.first(rr.Name) != app {
+		logging.VeryVerbose.Printf("dropped record %s of %+v: name claimed by %+v", rr, app, rg.claims.first(rr.Name))
+		return false
+	}
+	if !rg.Records.insert(rr) {
+		return false
+	}
+	if rr.Class != TaskClass && rg.owned != nil {
+		rg.owned[rr.Name] = struct{}{}
+	}
+	logging.VeryVerbose.Println("[" + dns.TypeToString[rr.Type] + "]\t" + rr.Name + ": " + rr.Value())
 	return true
 }
 
+// recordTTL returns the TTL of the given record set by its task's label or
+// configured for its record class, or zero if neither is set.
+func (rg *RecordGenerator) recordTTL(rr RR) uint32 {
+	if ttl, ok := rg.taskTTLs[rr.Origin.TaskID]; ok && rr.Origin.TaskID != "" {
+		return ttl
+	}
+	return rg.config.TTLs[rr.Class]
+}
+
 // leaderIP returns the ip for the mesos master
 // input format master@ip:port
 func leaderIP(leader string) string {
 	pair := strings.Split(leader, "@")[1]
-	return strings.Split(pair, ":")[0]
+	ip, _, _ := getProto(pair)
+	return ip
+}
+
+// txtString returns the RFC 1464 "key=value" string of the given pair,
+// truncated to the maximum length of a TXT character-string without
+// splitting UTF-8 encoded runes.
+func txtString(key, value string) string {
+	const maxLen = 255
+	txt := key + "=" + value
+	if len(txt) > maxLen {
+		n := maxLen
+		for n > 0 && !utf8.RuneStart(txt[n]) {
+			n--
+		}
+		txt = txt[:n]
+	}
+	return txt
+}
+
+// contains returns whether ss contains s.
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
 }
 
 // return the slave number from a Mesos slave id
@@ -575,6 +1602,10 @@ func slaveIDTail(slaveID string) string {
 // zk://username:password@host1:port1,host2:port2,.../path
 // file:///path/to/file (where file contains one of the above)
 func getProto(pair string) (string, string, error) {
+	// [ipv6]:port
+	if host, port, err := net.SplitHostPort(pair); err == nil {
+		return host, port, nil
+	}
 	h := strings.SplitN(pair, ":", 2)
 	if len(h) != 2 {
 		return "", "", fmt.Errorf("unable to parse proto from %q", pair)
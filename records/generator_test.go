@@ -8,14 +8,21 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"testing/quick"
 	"time"
+	"unicode/utf8"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/mesosphere/mesos-dns/logging"
 	"github.com/mesosphere/mesos-dns/records/labels"
 	"github.com/mesosphere/mesos-dns/records/state"
+	"github.com/mesosphere/mesos-dns/records/state/operator"
+	"github.com/miekg/dns"
 )
 
 func init() {
@@ -42,113 +49,104 @@ func TestMasterRecord(t *testing.T) {
 		{"foo.com", nil, "1@", nil},
 		{"foo.com", nil, "@2", nil},
 		{"foo.com", nil, "3@4", nil},
-		{"foo.com", nil, "5@6:7",
+		{"foo.com", nil, "5@1.1.1.6:7",
 			[]expectedRR{
-				{"leader.foo.com.", "6", "A"},
-				{"master.foo.com.", "6", "A"},
-				{"master0.foo.com.", "6", "A"},
+				{"leader.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.6", "A"},
+				{"master0.foo.com.", "1.1.1.6", "A"},
 				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
 				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
 			}},
 		// single master: leader and fallback
-		{"foo.com", []string{"6:7"}, "5@6:7",
+		{"foo.com", []string{"1.1.1.6:7"}, "5@1.1.1.6:7",
 			[]expectedRR{
-				{"leader.foo.com.", "6", "A"},
-				{"master.foo.com.", "6", "A"},
-				{"master0.foo.com.", "6", "A"},
+				{"leader.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.6", "A"},
+				{"master0.foo.com.", "1.1.1.6", "A"},
 				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
 				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
 			}},
 		// leader not in fallback list
-		{"foo.com", []string{"8:9"}, "5@6:7",
+		{"foo.com", []string{"1.1.1.8:9"}, "5@1.1.1.6:7",
 			[]expectedRR{
-				{"leader.foo.com.", "6", "A"},
-				{"master.foo.com.", "6", "A"},
-				{"master.foo.com.", "8", "A"},
-				{"master1.foo.com.", "6", "A"},
-				{"master0.foo.com.", "8", "A"},
+				{"leader.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.8", "A"},
+				{"master1.foo.com.", "1.1.1.6", "A"},
+				{"master0.foo.com.", "1.1.1.8", "A"},
 				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
 				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
 			}},
 		// duplicate fallback masters, leader not in fallback list
-		{"foo.com", []string{"8:9", "8:9"}, "5@6:7",
+		{"foo.com", []string{"1.1.1.8:9", "1.1.1.8:9"}, "5@1.1.1.6:7",
 			[]expectedRR{
-				{"leader.foo.com.", "6", "A"},
-				{"master.foo.com.", "6", "A"},
-				{"master.foo.com.", "8", "A"},
-				{"master1.foo.com.", "6", "A"},
-				{"master0.foo.com.", "8", "A"},
+				{"leader.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.8", "A"},
+				{"master1.foo.com.", "1.1.1.6", "A"},
+				{"master0.foo.com.", "1.1.1.8", "A"},
 				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
 				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
 			}},
 		// leader that's also listed in the fallback list (at the end)
-		{"foo.com", []string{"8:9", "6:7"}, "5@6:7",
+		{"foo.com", []string{"1.1.1.8:9", "1.1.1.6:7"}, "5@1.1.1.6:7",
 			[]expectedRR{
-				{"leader.foo.com.", "6", "A"},
-				{"master.foo.com.", "6", "A"},
-				{"master.foo.com.", "8", "A"},
-				{"master1.foo.com.", "6", "A"},
-				{"master0.foo.com.", "8", "A"},
+				{"leader.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.8", "A"},
+				{"master1.foo.com.", "1.1.1.6", "A"},
+				{"master0.foo.com.", "1.1.1.8", "A"},
 				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
 				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
 			}},
 		// duplicate leading masters in the fallback list
-		{"foo.com", []string{"8:9", "6:7", "6:7"}, "5@6:7",
+		{"foo.com", []string{"1.1.1.8:9", "1.1.1.6:7", "1.1.1.6:7"}, "5@1.1.1.6:7",
 			[]expectedRR{
-				{"leader.foo.com.", "6", "A"},
-				{"master.foo.com.", "6", "A"},
-				{"master.foo.com.", "8", "A"},
-				{"master1.foo.com.", "6", "A"},
-				{"master0.foo.com.", "8", "A"},
+				{"leader.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.8", "A"},
+				{"master1.foo.com.", "1.1.1.6", "A"},
+				{"master0.foo.com.", "1.1.1.8", "A"},
 				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
 				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
 			}},
-		// leader that's also listed in the fallback list (in the middle)
-		{"foo.com", []string{"8:9", "6:7", "bob:0"}, "5@6:7",
+		// leader that's also listed in the fallback list (in the middle), and a
+		// fallback master that isn't an IP address
+		{"foo.com", []string{"1.1.1.8:9", "1.1.1.6:7", "bob:0"}, "5@1.1.1.6:7",
 			[]expectedRR{
-				{"leader.foo.com.", "6", "A"},
-				{"master.foo.com.", "6", "A"},
-				{"master.foo.com.", "8", "A"},
-				{"master.foo.com.", "bob", "A"},
-				{"master0.foo.com.", "8", "A"},
-				{"master1.foo.com.", "6", "A"},
-				{"master2.foo.com.", "bob", "A"},
+				{"leader.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.6", "A"},
+				{"master.foo.com.", "1.1.1.8", "A"},
+				{"master0.foo.com.", "1.1.1.8", "A"},
+				{"master1.foo.com.", "1.1.1.6", "A"},
 				{"_leader._tcp.foo.com.", "leader.foo.com.:7", "SRV"},
 				{"_leader._udp.foo.com.", "leader.foo.com.:7", "SRV"},
 			}},
 	}
 	for i, tc := range tt {
 		rg := &RecordGenerator{}
-		rg.As = make(rrs)
-		rg.SRVs = make(rrs)
 		t.Logf("test case %d", i+1)
 		rg.masterRecord(tc.domain, tc.masters, tc.leader)
 		if tc.expect == nil {
-			if len(rg.As) > 0 {
-				t.Fatalf("test case %d: unexpected As: %v", i+1, rg.As)
-			}
-			if len(rg.SRVs) > 0 {
-				t.Fatalf("test case %d: unexpected SRVs: %v", i+1, rg.SRVs)
+			if n := rg.Records.Len(); n > 0 {
+				t.Fatalf("test case %d: unexpected records: %v", i+1, rg.Records.All())
 			}
 		}
-		expectedA := make(rrs)
-		expectedSRV := make(rrs)
+		expected := map[string][]string{}
 		for _, e := range tc.expect {
-			found := rg.exists(e.name, e.host, e.rtype)
-			if !found {
-				t.Fatalf("test case %d: missing expected record: name=%q host=%q rtype=%s, As=%v", i+1, e.name, e.host, e.rtype, rg.As)
-			}
-			if e.rtype == "A" {
-				expectedA[e.name] = append(expectedA[e.name], e.host)
-			} else {
-				expectedSRV[e.name] = append(expectedSRV[e.name], e.host)
-			}
+			key := e.rtype + " " + e.name
+			expected[key] = append(expected[key], e.host)
 		}
-		if !reflect.DeepEqual(rg.As, expectedA) {
-			t.Fatalf("test case %d: expected As of %v instead of %v", i+1, expectedA, rg.As)
+		got := map[string][]string{}
+		for _, rr := range rg.Records.All() {
+			if rr.Type == dns.TypePTR {
+				continue
+			}
+			key := dns.TypeToString[rr.Type] + " " + rr.Name
+			got[key] = append(got[key], rr.Value())
 		}
-		if !reflect.DeepEqual(rg.SRVs, expectedSRV) {
-			t.Fatalf("test case %d: expected SRVs of %v instead of %v", i+1, expectedSRV, rg.SRVs)
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("test case %d: expected records %v instead of %v", i+1, expected, got)
 		}
 	}
 }
@@ -164,6 +162,10 @@ func TestLeaderIP(t *testing.T) {
 }
 
 func testRecordGenerator(t *testing.T, spec labels.Func, ipSources []string) RecordGenerator {
+	return testRecordGeneratorWith(t, &RecordGenerator{}, spec, ipSources)
+}
+
+func testRecordGeneratorWith(t *testing.T, rg *RecordGenerator, spec labels.Func, ipSources []string) RecordGenerator {
 	var sj state.State
 
 	b, err := ioutil.ReadFile("../factories/fake.json")
@@ -176,12 +178,11 @@ func testRecordGenerator(t *testing.T, spec labels.Func, ipSources []string) Rec
 	sj.Leader = "master@144.76.157.37:5050"
 	masters := []string{"144.76.157.37:5050"}
 
-	var rg RecordGenerator
 	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", masters, ipSources, spec); err != nil {
 		t.Fatal(err)
 	}
 
-	return rg
+	return *rg
 }
 
 // ensure we are parsing what we think we are
@@ -192,59 +193,69 @@ func TestInsertState(t *testing.T) {
 	rgSlave := testRecordGenerator(t, labels.RFC952, []string{"host"})
 
 	for i, tt := range []struct {
-		rrs  rrs
-		name string
-		want []string
+		rrs   *RecordSet
+		rtype uint16
+		name  string
+		want  []string
 	}{
-		{rg.As, "liquor-store.marathon.mesos.", []string{"10.3.0.1", "10.3.0.2"}},
-		{rg.As, "liquor-store.marathon.slave.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
-		{rg.As, "car-store.marathon.slave.mesos.", []string{"1.2.3.11"}},
-		{rg.As, "nginx.marathon.mesos.", []string{"10.3.0.3"}},
-		{rg.As, "poseidon.marathon.mesos.", nil},
-		{rg.As, "poseidon.marathon.slave.mesos.", nil},
-		{rg.As, "master.mesos.", []string{"144.76.157.37"}},
-		{rg.As, "master0.mesos.", []string{"144.76.157.37"}},
-		{rg.As, "leader.mesos.", []string{"144.76.157.37"}},
-		{rg.As, "slave.mesos.", []string{"1.2.3.10", "1.2.3.11", "1.2.3.12"}},
-		{rg.As, "some-box.chronoswithaspaceandmixe.mesos.", []string{"1.2.3.11"}}, // ensure we translate the framework name as well
-		{rg.As, "marathon.mesos.", []string{"1.2.3.11"}},
-		{rg.SRVs, "_poseidon._tcp.marathon.mesos.", nil},
-		{rg.SRVs, "_leader._tcp.mesos.", []string{"leader.mesos.:5050"}},
-		{rg.SRVs, "_liquor-store._tcp.marathon.mesos.", []string{
+		{rg.Records, dns.TypeA, "liquor-store.marathon.mesos.", []string{"10.3.0.1", "10.3.0.2"}},
+		{rg.Records, dns.TypeA, "liquor-store.marathon.slave.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
+		{rg.Records, dns.TypeA, "car-store.marathon.slave.mesos.", []string{"1.2.3.11"}},
+		{rg.Records, dns.TypeA, "nginx.marathon.mesos.", []string{"10.3.0.3"}},
+		{rg.Records, dns.TypeA, "poseidon.marathon.mesos.", nil},
+		{rg.Records, dns.TypeA, "poseidon.marathon.slave.mesos.", nil},
+		{rg.Records, dns.TypeA, "master.mesos.", []string{"144.76.157.37"}},
+		{rg.Records, dns.TypeA, "master0.mesos.", []string{"144.76.157.37"}},
+		{rg.Records, dns.TypeA, "leader.mesos.", []string{"144.76.157.37"}},
+		{rg.Records, dns.TypeA, "slave.mesos.", []string{"1.2.3.10", "1.2.3.11", "1.2.3.12"}},
+		{rg.Records, dns.TypeA, "some-box.chronoswithaspaceandmixe.mesos.", []string{"1.2.3.11"}}, // ensure we translate the framework name as well
+		{rg.Records, dns.TypeA, "marathon.mesos.", []string{"1.2.3.11"}},
+		{rg.Records, dns.TypeSRV, "_poseidon._tcp.marathon.mesos.", nil},
+		{rg.Records, dns.TypeSRV, "_leader._tcp.mesos.", []string{"leader.mesos.:5050"}},
+		{rg.Records, dns.TypeSRV, "_liquor-store._tcp.marathon.mesos.", []string{
 			"liquor-store-4dfjd-0.marathon.mesos.:80",
 			"liquor-store-4dfjd-0.marathon.mesos.:443",
 			"liquor-store-zasmd-1.marathon.mesos.:80",
 			"liquor-store-zasmd-1.marathon.mesos.:443",
 		}},
-		{rg.SRVs, "_liquor-store._udp.marathon.mesos.", nil},
-		{rg.SRVs, "_liquor-store.marathon.mesos.", nil},
-		{rg.SRVs, "_car-store._tcp.marathon.mesos.", []string{
+		{rg.Records, dns.TypeSRV, "_http._liquor-store._tcp.marathon.mesos.", []string{
+			"liquor-store-4dfjd-0.marathon.mesos.:80",
+			"liquor-store-zasmd-1.marathon.mesos.:80",
+		}},
+		{rg.Records, dns.TypeSRV, "_https._liquor-store._tcp.mesos.", []string{
+			"liquor-store-4dfjd-0.marathon.mesos.:443",
+			"liquor-store-zasmd-1.marathon.mesos.:443",
+		}},
+		{rg.Records, dns.TypeSRV, "_http._liquor-store._udp.marathon.mesos.", nil},
+		{rg.Records, dns.TypeSRV, "_liquor-store._udp.marathon.mesos.", nil},
+		{rg.Records, dns.TypeSRV, "_liquor-store.marathon.mesos.", nil},
+		{rg.Records, dns.TypeSRV, "_car-store._tcp.marathon.mesos.", []string{
 			"car-store-zinaz-0.marathon.slave.mesos.:31364",
 			"car-store-zinaz-0.marathon.slave.mesos.:31365",
 		}},
-		{rg.SRVs, "_car-store._udp.marathon.mesos.", []string{
+		{rg.Records, dns.TypeSRV, "_car-store._udp.marathon.mesos.", []string{
 			"car-store-zinaz-0.marathon.slave.mesos.:31364",
 			"car-store-zinaz-0.marathon.slave.mesos.:31365",
 		}},
-		{rg.SRVs, "_slave._tcp.mesos.", []string{"slave.mesos.:5051"}},
-		{rg.SRVs, "_framework._tcp.marathon.mesos.", []string{"marathon.mesos.:25501"}},
-
-		{rgSlave.As, "liquor-store.marathon.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
-		{rgSlave.As, "liquor-store.marathon.slave.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
-		{rgSlave.As, "nginx.marathon.mesos.", []string{"1.2.3.11"}},
-		{rgSlave.As, "car-store.marathon.slave.mesos.", []string{"1.2.3.11"}},
-
-		{rgMesos.As, "liquor-store.marathon.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
-		{rgMesos.As, "liquor-store.marathon.slave.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
-		{rgMesos.As, "nginx.marathon.mesos.", []string{"10.3.0.3"}},
-		{rgMesos.As, "car-store.marathon.slave.mesos.", []string{"1.2.3.11"}},
-
-		{rgDocker.As, "liquor-store.marathon.mesos.", []string{"10.3.0.1", "10.3.0.2"}},
-		{rgDocker.As, "liquor-store.marathon.slave.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
-		{rgDocker.As, "nginx.marathon.mesos.", []string{"1.2.3.11"}},
-		{rgDocker.As, "car-store.marathon.slave.mesos.", []string{"1.2.3.11"}},
+		{rg.Records, dns.TypeSRV, "_slave._tcp.mesos.", []string{"slave.mesos.:5051"}},
+		{rg.Records, dns.TypeSRV, "_framework._tcp.marathon.mesos.", []string{"marathon.mesos.:25501"}},
+
+		{rgSlave.Records, dns.TypeA, "liquor-store.marathon.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
+		{rgSlave.Records, dns.TypeA, "liquor-store.marathon.slave.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
+		{rgSlave.Records, dns.TypeA, "nginx.marathon.mesos.", []string{"1.2.3.11"}},
+		{rgSlave.Records, dns.TypeA, "car-store.marathon.slave.mesos.", []string{"1.2.3.11"}},
+
+		{rgMesos.Records, dns.TypeA, "liquor-store.marathon.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
+		{rgMesos.Records, dns.TypeA, "liquor-store.marathon.slave.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
+		{rgMesos.Records, dns.TypeA, "nginx.marathon.mesos.", []string{"10.3.0.3"}},
+		{rgMesos.Records, dns.TypeA, "car-store.marathon.slave.mesos.", []string{"1.2.3.11"}},
+
+		{rgDocker.Records, dns.TypeA, "liquor-store.marathon.mesos.", []string{"10.3.0.1", "10.3.0.2"}},
+		{rgDocker.Records, dns.TypeA, "liquor-store.marathon.slave.mesos.", []string{"1.2.3.11", "1.2.3.12"}},
+		{rgDocker.Records, dns.TypeA, "nginx.marathon.mesos.", []string{"1.2.3.11"}},
+		{rgDocker.Records, dns.TypeA, "car-store.marathon.slave.mesos.", []string{"1.2.3.11"}},
 	} {
-		if got := tt.rrs[tt.name]; !reflect.DeepEqual(got, tt.want) {
+		if got := values(tt.rrs, tt.name, tt.rtype); !reflect.DeepEqual(got, tt.want) {
 			t.Errorf("test #%d: %q: got: %q, want: %q", i, tt.name, got, tt.want)
 		}
 	}
@@ -253,19 +264,36 @@ func TestInsertState(t *testing.T) {
 // ensure we only generate one A record for each host
 func TestNTasks(t *testing.T) {
 	rg := &RecordGenerator{}
-	rg.As = make(rrs)
 
-	rg.insertRR("blah.mesos", "10.0.0.1", "A")
-	rg.insertRR("blah.mesos", "10.0.0.1", "A")
-	rg.insertRR("blah.mesos", "10.0.0.2", "A")
+	rg.insertAddr(TaskClass, "blah.mesos", "10.0.0.1", Origin{})
+	rg.insertAddr(TaskClass, "blah.mesos", "10.0.0.1", Origin{})
+	rg.insertAddr(TaskClass, "blah.mesos", "10.0.0.2", Origin{})
 
-	k, _ := rg.As["blah.mesos"]
+	k := rg.Records.Get("blah.mesos", dns.TypeA)
 
 	if len(k) != 2 {
 		t.Error("should only have 2 A records")
 	}
 }
 
+func TestTxtString(t *testing.T) {
+	for i, tt := range []struct {
+		key, value string
+		want       string
+	}{
+		{"k", "v", "k=v"},
+		{"k", strings.Repeat("v", 300), "k=" + strings.Repeat("v", 253)},
+		// "é" is 2 bytes long, the last one of which would be cut off
+		{"k", "v" + strings.Repeat("é", 150), "k=v" + strings.Repeat("é", 126)},
+		{"k", strings.Repeat("日", 100), "k=" + strings.Repeat("日", 84)},
+	} {
+		got := txtString(tt.key, tt.value)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("test #%d: got %q (%d bytes), want %q", i, got, len(got), tt.want)
+		}
+	}
+}
+
 func TestHashString(t *testing.T) {
 	val := hashString("test")
 	if len(val) != 5 {
@@ -323,3 +351,1029 @@ func TestTimeout(t *testing.T) {
 		t.Errorf("Did not receive a timeout, instead: %#v", err)
 	}
 }
+
+func TestLoadFromOperatorAPI(t *testing.T) {
+	responses := map[operator.CallType]*operator.Response{
+		operator.GetMaster: {GetMaster: &operator.GetMasterResponse{
+			MasterInfo: operator.MasterInfo{ID: "m", IP: 0x0100000a, Port: 5050},
+		}},
+		operator.GetState: {GetState: &operator.GetStateResponse{
+			GetAgents: operator.GetAgentsResponse{Agents: []operator.Agent{{
+				AgentInfo: operator.AgentInfo{Hostname: "10.0.0.2", ID: operator.ID{Value: "s0"}},
+			}}},
+		}},
+	}
+	for _, encoding := range []string{JSONStateEncoding, ProtobufStateEncoding} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method != "POST" || req.URL.Path != operator.Path {
+				http.NotFound(w, req)
+				return
+			}
+			body, _ := ioutil.ReadAll(req.Body)
+			var call operator.Call
+			var err error
+			var data []byte
+			if req.Header.Get("Content-Type") == operator.ProtobufContentType {
+				if err = proto.Unmarshal(body, &call); err == nil {
+					data, err = proto.Marshal(responses[call.Type])
+				}
+			} else if err = json.Unmarshal(body, &call); err == nil {
+				data, err = json.Marshal(responses[call.Type])
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", req.Header.Get("Accept"))
+			_, _ = w.Write(data)
+		}))
+
+		c := NewConfig()
+		c.StateAPI, c.StateEncoding = OperatorStateAPI, encoding
+		rg := NewRecordGenerator(time.Second, WithConfig(c))
+		host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+		sj, err := rg.loadFromMaster(host, port)
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: %v", encoding, err)
+			continue
+		}
+		if sj.Leader != "master@10.0.0.1:5050" || len(sj.Slaves) != 1 || sj.Slaves[0].ID != "s0" {
+			t.Errorf("%s: got state %+v", encoding, sj)
+		}
+	}
+}
+
+func TestInsertState_OperatorFrameworks(t *testing.T) {
+	r := operator.GetStateResponse{GetFrameworks: operator.GetFrameworksResponse{Frameworks: []operator.Framework{
+		{FrameworkInfo: operator.FrameworkInfo{ID: operator.ID{Value: "fw0"}, Name: "marathon", Hostname: "10.0.0.5"}},
+		{FrameworkInfo: operator.FrameworkInfo{ID: operator.ID{Value: "fw1"}, Name: "chronos", WebUIURL: "http://10.0.0.6:4400"}},
+	}}}
+	sj, err := r.State("master@10.0.0.1:5050")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rg := NewRecordGenerator(0, WithConfig(NewConfig()))
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	// the operator API doesn't tell the ports of schedulers, so frameworks
+	// get A records only
+	for i, tt := range []struct {
+		name  string
+		rtype uint16
+		want  []string
+	}{
+		{"marathon.mesos.", dns.TypeA, []string{"10.0.0.5"}},
+		{"fw0.frameworks.mesos.", dns.TypeA, []string{"10.0.0.5"}},
+		{"chronos.mesos.", dns.TypeA, []string{"10.0.0.6"}},
+		{"_framework._tcp.marathon.mesos.", dns.TypeSRV, nil},
+	} {
+		if got := values(rg.Records, tt.name, tt.rtype); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %s: got %q, want %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_IPMode(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@[2001:db8::1]:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [{
+				"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				"statuses": [{"state": "TASK_RUNNING", "container_status": {"network_infos": [
+					{"ip_addresses": [{"ip_address": "10.0.0.1"}, {"ip_address": "fd00::1"}]}
+				]}}]
+			}]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		mode       string
+		as, aaaas  []string
+		leaderAAAA []string
+	}{
+		{DualMode, []string{"10.0.0.1"}, []string{"fd00::1"}, []string{"2001:db8::1"}},
+		{IPv4Mode, []string{"10.0.0.1"}, nil, nil},
+		{IPv6Mode, nil, []string{"fd00::1"}, []string{"2001:db8::1"}},
+	} {
+		c := NewConfig()
+		c.IPMode = tt.mode
+		rg := NewRecordGenerator(0, WithConfig(c))
+		if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"netinfo", "host"}, labels.RFC1123); err != nil {
+			t.Fatal(err)
+		}
+		if got := values(rg.Records, "web.marathon.mesos.", dns.TypeA); !reflect.DeepEqual(got, tt.as) {
+			t.Errorf("test #%d: A: got %q, want %q", i, got, tt.as)
+		}
+		if got := values(rg.Records, "web.marathon.mesos.", dns.TypeAAAA); !reflect.DeepEqual(got, tt.aaaas) {
+			t.Errorf("test #%d: AAAA: got %q, want %q", i, got, tt.aaaas)
+		}
+		if got := values(rg.Records, "leader.mesos.", dns.TypeAAAA); !reflect.DeepEqual(got, tt.leaderAAAA) {
+			t.Errorf("test #%d: leader AAAA: got %q, want %q", i, got, tt.leaderAAAA)
+		}
+	}
+}
+
+func TestInsertState_TXT(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [{
+				"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				"labels": [{"key": "version", "value": "1.2"}, {"key": "secret", "value": "s3cr3t"}],
+				"discovery": {"name": "web", "labels": {"labels": [{"key": "protocol", "value": "http"}]}}
+			}]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig()
+	c.TXTLabels = []string{"version", "protocol", "missing"}
+	rg := NewRecordGenerator(0, WithConfig(c))
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	canonical := "web-" + hashString("web.1") + "-0.marathon.mesos."
+	for i, tt := range []struct {
+		name string
+		want []string
+	}{
+		{"web.marathon.mesos.", []string{"version=1.2", "protocol=http"}},
+		{canonical, []string{"version=1.2", "protocol=http", "task_id=web.1", "framework=marathon", "agent_id=s-0"}},
+	} {
+		if got := values(rg.Records, tt.name, dns.TypeTXT); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got %q, want %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_SRVOptions(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [
+				{"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "resources": {"cpus": 2, "ports": "[31000-31000]"}},
+				{"id": "web.2", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "resources": {"cpus": 0.5, "ports": "[31001-31001]"}},
+				{"id": "web.3", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "labels": [{"key": "DNS_SRV_PRIORITY", "value": "10"}, {"key": "DNS_SRV_WEIGHT", "value": "7"}],
+				 "resources": {"cpus": 1, "ports": "[31002-31002]"}}
+			]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig()
+	c.SRVWeightResource = "cpus"
+	rg := NewRecordGenerator(0, WithConfig(c))
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	target := func(id, port string) string {
+		return "web-" + hashString(id) + "-0.marathon.slave.mesos.:" + port
+	}
+	want := map[string]SRVOptions{
+		target("web.1", "31000"): {Priority: 0, Weight: 100},
+		target("web.2", "31001"): {Priority: 0, Weight: 25},
+		target("web.3", "31002"): {Priority: 10, Weight: 7},
+	}
+	got := map[string]SRVOptions{}
+	for _, rr := range rg.Records.Get("_web._tcp.marathon.mesos.", dns.TypeSRV) {
+		got[rr.Value()] = SRVOptions{Priority: rr.Priority, Weight: rr.Weight}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestInsertState_Agents(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [
+			{"id": "20160107-001256-134875658-5050-27524-S1", "hostname": "Agent1.example.com", "pid": "slave(1)@1.2.3.4:5051"},
+			{"id": "20160107-001256-134875658-5050-27524-S2", "hostname": "1.2.3.5", "pid": "slave(1)@1.2.3.5:5051"}
+		]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+	var rg RecordGenerator
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, nil, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		name  string
+		rtype uint16
+		want  []string
+	}{
+		{"slave.mesos.", dns.TypeA, []string{"1.2.3.4", "1.2.3.5"}},
+		{"agent1.example.com.agents.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{"s1.agents.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{"s2.agents.mesos.", dns.TypeA, []string{"1.2.3.5"}},
+		{"1.2.3.5.agents.mesos.", dns.TypeA, nil},
+		{"_slave._tcp.agent1.example.com.agents.mesos.", dns.TypeSRV, []string{"agent1.example.com.agents.mesos.:5051"}},
+		{"_slave._tcp.s2.agents.mesos.", dns.TypeSRV, []string{"s2.agents.mesos.:5051"}},
+		{"4.3.2.1.in-addr.arpa.", dns.TypePTR, []string{"agent1.example.com.agents.mesos."}},
+		{"5.3.2.1.in-addr.arpa.", dns.TypePTR, []string{"s2.agents.mesos."}},
+	} {
+		if got := values(rg.Records, tt.name, tt.rtype); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %s %q: got %q, want %q", i, dns.TypeToString[tt.rtype], tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_AgentAttributes(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [
+			{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051", "attributes": {"rack": 12, "zone": "b"}},
+			{"id": "s-1", "pid": "slave(1)@1.2.3.5:5051", "attributes": {"rack": 12, "zone": "c"}},
+			{"id": "s-2", "pid": "slave(1)@1.2.3.6:5051"}
+		],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [
+				{"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING"},
+				{"id": "web.2", "name": "web", "slave_id": "s-1", "state": "TASK_RUNNING"},
+				{"id": "web.3", "name": "web", "slave_id": "s-2", "state": "TASK_RUNNING"}
+			]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig()
+	c.AgentAttributes = []string{"rack", "zone"}
+	c.NameTemplates = []NameTemplate{{"{name}.{attribute:zone}", TaskTarget}}
+	rg := NewRecordGenerator(0, WithConfig(c))
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		name string
+		want []string
+	}{
+		{"slave.rack-12.mesos.", []string{"1.2.3.4", "1.2.3.5"}},
+		{"slave.zone-b.mesos.", []string{"1.2.3.4"}},
+		{"web.marathon.zone-b.mesos.", []string{"1.2.3.4"}},
+		{"web.marathon.zone-c.mesos.", []string{"1.2.3.5"}},
+		{"web.marathon.rack-12.mesos.", []string{"1.2.3.4", "1.2.3.5"}},
+		{"web.b.mesos.", []string{"1.2.3.4"}},
+		{"web.c.mesos.", []string{"1.2.3.5"}},
+	} {
+		if got := values(rg.Records, tt.name, dns.TypeA); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got %q, want %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_FrameworkZones(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [
+			{"id": "fw0", "name": "marathon", "role": "prod", "pid": "scheduler@1.2.3.2:8080",
+			 "tasks": [{"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+			            "resources": {"ports": "[31000-31000]"}}]},
+			{"id": "fw1", "name": "marathon", "role": "*", "roles": ["dev", "qa"], "pid": "scheduler@1.2.3.3:8080",
+			 "tasks": [{"id": "web.2", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+			            "resources": {"ports": "[31001-31001]"}}]}
+		]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+	var rg RecordGenerator
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	host := func(id string) string {
+		return "web-" + hashString(id) + "-0.marathon.slave.mesos."
+	}
+	for i, tt := range []struct {
+		name  string
+		rtype uint16
+		want  []string
+	}{
+		{"marathon.mesos.", dns.TypeA, []string{"1.2.3.2", "1.2.3.3"}},
+		{"fw0.frameworks.mesos.", dns.TypeA, []string{"1.2.3.2"}},
+		{"_framework._tcp.fw1.frameworks.mesos.", dns.TypeSRV, []string{"fw1.frameworks.mesos.:8080"}},
+		{"web.fw0.frameworks.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{"_web._tcp.fw0.frameworks.mesos.", dns.TypeSRV, []string{host("web.1") + ":31000"}},
+		{"_web._udp.fw1.frameworks.mesos.", dns.TypeSRV, []string{host("web.2") + ":31001"}},
+		{"web.marathon.prod.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{"_web._tcp.marathon.qa.mesos.", dns.TypeSRV, []string{host("web.2") + ":31001"}},
+		{"web.marathon.dev.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{"_web._tcp.marathon.dev.mesos.", dns.TypeSRV, []string{host("web.2") + ":31001"}},
+	} {
+		if got := values(rg.Records, tt.name, tt.rtype); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %s %q: got %q, want %q", i, dns.TypeToString[tt.rtype], tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_FrameworkIDNames(t *testing.T) {
+	for _, spec := range []labels.Func{labels.RFC952, labels.RFC1123} {
+		rg := testRecordGenerator(t, spec, []string{"host"})
+		for i, tt := range []struct {
+			name  string
+			rtype uint16
+			want  []string
+		}{
+			{"20140703-014514-3041283216-5050-5348-0000.frameworks.mesos.", dns.TypeA, []string{"1.2.3.11"}},
+			{"_framework._tcp.20140703-014514-3041283216-5050-5348-0001.frameworks.mesos.", dns.TypeSRV,
+				[]string{"20140703-014514-3041283216-5050-5348-0001.frameworks.mesos.:25501"}},
+		} {
+			if got := values(rg.Records, tt.name, tt.rtype); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("test #%d: %s %q: got %q, want %q", i, dns.TypeToString[tt.rtype], tt.name, got, tt.want)
+			}
+		}
+	}
+
+	for i, tt := range []struct{ id, want string }{
+		{"20140703-014514-3041283216-5050-5348-0001", "20140703-014514-3041283216-5050-5348-0001"},
+		{"Marathon_Prod.1", "marathon-prod-1"},
+		{"-fw 0-", "fw0"},
+		{"?", ""},
+		{strings.Repeat("f", 64), ""},
+	} {
+		if got := frameworkIDName(tt.id); got != tt.want {
+			t.Errorf("test #%d: frameworkIDName(%q): got %q, want %q", i, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_Aliases(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{"id": "fw0", "name": "marathon", "tasks": [
+			{"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+			 "resources": {"ports": "[31000-31000]"},
+			 "labels": [{"key": "DNS_ALIASES", "value": "api.mesos, API.Prod.mesos., api.example.com,mesos"}]},
+			{"id": "db.1", "name": "db", "slave_id": "s-0", "state": "TASK_RUNNING",
+			 "labels": [{"key": "DNS_ALIASES", "value": "web.marathon.mesos,shared.mesos"}]},
+			{"id": "evil.1", "name": "evil", "slave_id": "s-0", "state": "TASK_RUNNING",
+			 "resources": {"ports": "[31001-31001]"},
+			 "labels": [{"key": "DNS_ALIASES", "value": "leader.mesos,master0.mesos,slave.mesos,ns1.mesos,s-0.agents.mesos,x.frameworks.mesos,ok.mesos"}]}
+		]}, {"id": "fw1", "name": "aurora", "tasks": [
+			{"id": "job.1", "name": "job", "slave_id": "s-0", "state": "TASK_RUNNING",
+			 "labels": [{"key": "DNS_ALIASES", "value": "shared.mesos"}]}
+		]}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := "web-" + hashString("web.1") + "-0.marathon.slave.mesos.:31000"
+	for i, tt := range []struct {
+		mode  string
+		name  string
+		rtype uint16
+		want  []string
+	}{
+		{DirectAliasMode, "api.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{DirectAliasMode, "api.prod.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{DirectAliasMode, "_api._tcp.mesos.", dns.TypeSRV, []string{srv}},
+		{DirectAliasMode, "_api._udp.prod.mesos.", dns.TypeSRV, []string{srv}},
+		{DirectAliasMode, "api.example.com.mesos.", dns.TypeA, nil},
+		{DirectAliasMode, "api.mesos.", dns.TypeCNAME, nil},
+		{CNAMEAliasMode, "api.mesos.", dns.TypeCNAME, []string{"web.marathon.mesos."}},
+		{CNAMEAliasMode, "api.prod.mesos.", dns.TypeCNAME, []string{"web.marathon.mesos."}},
+		{CNAMEAliasMode, "_api._tcp.prod.mesos.", dns.TypeCNAME, []string{"_web._tcp.marathon.mesos."}},
+		{CNAMEAliasMode, "api.mesos.", dns.TypeA, nil},
+		{CNAMEAliasMode, "web.marathon.mesos.", dns.TypeCNAME, nil}, // shadowing
+		{CNAMEAliasMode, "web.marathon.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		// names owned by mesos-dns can't be taken
+		{DirectAliasMode, "leader.mesos.", dns.TypeA, []string{"1.2.3.1"}},
+		{DirectAliasMode, "_leader._tcp.mesos.", dns.TypeSRV, []string{"leader.mesos.:5050"}},
+		{DirectAliasMode, "master0.mesos.", dns.TypeA, []string{"1.2.3.1"}},
+		{DirectAliasMode, "slave.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{DirectAliasMode, "_slave._tcp.mesos.", dns.TypeSRV, []string{"slave.mesos.:5051"}},
+		{DirectAliasMode, "ns1.mesos.", dns.TypeA, nil},
+		{DirectAliasMode, "x.frameworks.mesos.", dns.TypeA, nil},
+		{CNAMEAliasMode, "leader.mesos.", dns.TypeCNAME, nil},
+		{CNAMEAliasMode, "s-0.agents.mesos.", dns.TypeCNAME, nil},
+		{DirectAliasMode, "_ok._tcp.mesos.", dns.TypeSRV, []string{"evil-" + hashString("evil.1") + "-0.marathon.slave.mesos.:31001"}},
+		// aliases claimed by several apps keep a single CNAME record
+		{CNAMEAliasMode, "shared.mesos.", dns.TypeCNAME, []string{"db.marathon.mesos."}},
+	} {
+		c := NewConfig()
+		c.AliasMode = tt.mode
+		rg := NewRecordGenerator(0, WithConfig(c))
+		if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+			t.Fatal(err)
+		}
+		if got := values(rg.Records, tt.name, tt.rtype); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %s %s %q: got %q, want %q", i, tt.mode, dns.TypeToString[tt.rtype], tt.name, got, tt.want)
+		}
+		if tt.name != "shared.mesos." {
+			continue
+		}
+		var found bool
+		for _, c := range rg.Collisions {
+			found = found || c.Name == tt.name
+		}
+		if !found {
+			t.Errorf("test #%d: collision of %s not reported", i, tt.name)
+		}
+	}
+}
+
+func TestInsertState_StaticFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	zone := filepath.Join(dir, "infra.zone")
+	err = ioutil.WriteFile(zone, []byte(`
+db                IN A  10.0.0.5
+marathon          IN A  10.0.0.6
+sub               IN NS ns.sub
+@                 IN NS ns.example.com.
+db.example.com.   IN A  10.0.0.7
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sj state.State
+	if err := json.Unmarshal([]byte(`{"frameworks": [{"name": "marathon", "pid": "scheduler@1.2.3.2:8080"}]}`), &sj); err != nil {
+		t.Fatal(err)
+	}
+	c := NewConfig()
+	c.StaticFiles = []string{zone}
+	rg := NewRecordGenerator(0, WithConfig(c))
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, nil, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		name  string
+		rtype uint16
+		want  []string
+	}{
+		{"db.mesos.", dns.TypeA, []string{"10.0.0.5"}},
+		{"marathon.mesos.", dns.TypeA, []string{"1.2.3.2", "10.0.0.6"}},
+		{"sub.mesos.", dns.TypeNS, []string{"ns.sub.mesos."}},
+		{"mesos.", dns.TypeNS, nil},
+		{"db.example.com.", dns.TypeA, nil},
+	} {
+		if got := values(rg.Records, tt.name, tt.rtype); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %s %q: got %q, want %q", i, dns.TypeToString[tt.rtype], tt.name, got, tt.want)
+		}
+	}
+	for _, rr := range rg.Records.Get("db.mesos.", dns.TypeA) {
+		if rr.Class != StaticClass {
+			t.Errorf("got class %q, want %q", rr.Class, StaticClass)
+		}
+	}
+}
+
+func TestInsertState_Networks(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [{
+				"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				"statuses": [{"state": "TASK_RUNNING", "container_status": {"network_infos": [
+					{"name": "front", "ip_addresses": [{"ip_address": "10.0.0.1"}, {"ip_address": "fd00::1"}]},
+					{"name": "back", "ip_addresses": [{"ip_address": "10.1.0.1"}]}
+				]}}]
+			}]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+	var rg RecordGenerator
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"netinfo", "host"}, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		name  string
+		rtype uint16
+		want  []string
+	}{
+		{"web.marathon.mesos.", dns.TypeA, []string{"10.0.0.1", "10.1.0.1"}},
+		{"web.marathon.mesos.", dns.TypeAAAA, []string{"fd00::1"}},
+		{"web.marathon.front.mesos.", dns.TypeA, []string{"10.0.0.1"}},
+		{"web.marathon.front.mesos.", dns.TypeAAAA, []string{"fd00::1"}},
+		{"web.marathon.back.mesos.", dns.TypeA, []string{"10.1.0.1"}},
+		{"web.marathon.back.mesos.", dns.TypeAAAA, nil},
+	} {
+		if got := values(rg.Records, tt.name, tt.rtype); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %s %q: got %q, want %q", i, dns.TypeToString[tt.rtype], tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_Collisions(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [
+			{"id": "fw0", "name": "marathon", "tasks": [
+				{"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "statuses": [{"state": "TASK_RUNNING", "container_status": {"network_infos": [{"ip_address": "10.0.0.1"}]}}]},
+				{"id": "web.2", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "statuses": [{"state": "TASK_RUNNING", "container_status": {"network_infos": [{"ip_address": "10.0.0.2"}]}}]},
+				{"id": "api.1", "name": "api", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "statuses": [{"state": "TASK_RUNNING", "container_status": {"network_infos": [{"ip_address": "10.0.0.3"}]}}]}
+			]},
+			{"id": "fw1", "name": "aurora", "tasks": [
+				{"id": "web.3", "name": "Web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "statuses": [{"state": "TASK_RUNNING", "container_status": {"network_infos": [{"ip_address": "10.1.0.1"}]}}]}
+			]}
+		]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	marathon := App{FrameworkID: "fw0", Framework: "marathon", Task: "web"}
+	aurora := App{FrameworkID: "fw1", Framework: "aurora", Task: "Web"}
+	for i, tt := range []struct {
+		policy string
+		name   string
+		want   []string
+	}{
+		{MergePolicy, "web.mesos.", []string{"10.0.0.1", "10.0.0.2", "10.1.0.1"}},
+		{FirstWinsPolicy, "web.mesos.", []string{"10.0.0.1", "10.0.0.2"}},
+		{DropPolicy, "web.mesos.", nil},
+		{DropPolicy, "web.marathon.mesos.", []string{"10.0.0.1", "10.0.0.2"}},
+		{DropPolicy, "api.mesos.", []string{"10.0.0.3"}},
+	} {
+		c := NewConfig()
+		c.CollisionPolicy = tt.policy
+		rg := NewRecordGenerator(0, WithConfig(c))
+		if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"netinfo"}, labels.RFC1123); err != nil {
+			t.Fatal(err)
+		}
+		if got := values(rg.Records, tt.name, dns.TypeA); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %s %q: got %q, want %q", i, tt.policy, tt.name, got, tt.want)
+		}
+
+		var found bool
+		for _, c := range rg.Collisions {
+			if c.Name == "api.mesos." || c.Name == "web.marathon.mesos." {
+				t.Errorf("test #%d: unexpected collision %+v", i, c)
+			}
+			if c.Name == "web.mesos." {
+				found = true
+				if want := []App{marathon, aurora}; !reflect.DeepEqual(c.Apps, want) {
+					t.Errorf("test #%d: got apps %+v, want %+v", i, c.Apps, want)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("test #%d: collision of web.mesos. not detected", i)
+		}
+	}
+}
+
+func TestInsertState_FrameworkCollisions(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"frameworks": [
+			{"id": "fw0", "name": "marathon"},
+			{"id": "fw1", "name": "Marathon"},
+			{"id": "fw2", "name": "chronos"}
+		]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+	var rg RecordGenerator
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, nil, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Collision{{Name: "marathon.mesos.", Apps: []App{
+		{FrameworkID: "fw0", Framework: "marathon"},
+		{FrameworkID: "fw1", Framework: "Marathon"},
+	}}}
+	if !reflect.DeepEqual(rg.Collisions, want) {
+		t.Errorf("got collisions %+v, want %+v", rg.Collisions, want)
+	}
+}
+
+func TestInsertState_FakeCollisions(t *testing.T) {
+	for _, spec := range []labels.Func{labels.RFC952, labels.RFC1123} {
+		rg := testRecordGenerator(t, spec, []string{"host"})
+		for _, c := range rg.Collisions {
+			if strings.HasSuffix(c.Name, ".frameworks.mesos.") {
+				t.Errorf("unexpected collision of framework IDs %+v", c)
+			}
+		}
+	}
+}
+
+func TestInsertState_KnownCollisions(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [
+			{"id": "fw0", "name": "marathon", "tasks": [
+				{"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING"}
+			]},
+			{"id": "fw1", "name": "aurora", "tasks": [
+				{"id": "web.2", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING"}
+			]}
+		]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	var counter countingCounter
+	defer func(c logging.Counter) { logging.CurLog.NameCollisions = c }(logging.CurLog.NameCollisions)
+	logging.CurLog.NameCollisions = &counter
+
+	// collisions are counted in the generation they're first found only
+	var known []Collision
+	for i := 0; i < 3; i++ {
+		rg := NewRecordGenerator(0, WithConfig(NewConfig()), WithKnownCollisions(known))
+		if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+			t.Fatal(err)
+		}
+		if len(rg.Collisions) == 0 {
+			t.Fatalf("generation #%d: no collisions", i)
+		}
+		if i == 0 {
+			known = rg.Collisions
+		}
+		if int(counter) != len(known) {
+			t.Errorf("generation #%d: counted %d collisions, want %d", i, counter, len(known))
+		}
+		known = rg.Collisions
+	}
+}
+
+// countingCounter is a logging.Counter counting its increments.
+type countingCounter int
+
+func (c *countingCounter) Inc() { *c++ }
+
+func TestInsertState_TTL(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [
+				{"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "resources": {"ports": "[31000-31000]"}},
+				{"id": "db.1", "name": "db", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "labels": [{"key": "DNS_TTL", "value": "5"}],
+				 "resources": {"ports": "[31001-31001]"}}
+			]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig()
+	c.TTLs = map[string]uint32{MasterClass: 3600, TaskClass: 30}
+	rg := NewRecordGenerator(0, WithConfig(c))
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		name  string
+		rtype uint16
+		ttl   uint32
+	}{
+		{"leader.mesos.", dns.TypeA, 3600},
+		{"_leader._tcp.mesos.", dns.TypeSRV, 3600},
+		{"slave.mesos.", dns.TypeA, 0},
+		{"web.marathon.mesos.", dns.TypeA, 30},
+		{"_web._tcp.marathon.mesos.", dns.TypeSRV, 30},
+		{"db.marathon.mesos.", dns.TypeA, 5},
+		{"db.marathon.slave.mesos.", dns.TypeA, 5},
+		{"_db._tcp.marathon.mesos.", dns.TypeSRV, 5},
+		{"db-" + hashString("db.1") + "-0.marathon.mesos.", dns.TypeTXT, 5},
+	} {
+		rrs := rg.Records.Get(tt.name, tt.rtype)
+		if len(rrs) == 0 {
+			t.Errorf("test #%d: no %s records of %q", i, dns.TypeToString[tt.rtype], tt.name)
+		}
+		for _, rr := range rrs {
+			if rr.TTL != tt.ttl {
+				t.Errorf("test #%d: %s: got TTL %d, want %d", i, rr, rr.TTL, tt.ttl)
+			}
+		}
+	}
+}
+
+func TestInsertState_NameTemplates(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [
+				{"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "labels": [{"key": "team", "value": "Core"}, {"key": "DNS_ALIASES", "value": "api.mesos"}],
+				 "discovery": {"name": "web", "environment": "prod"},
+				 "statuses": [{"state": "TASK_RUNNING", "labels": [
+					{"key": "Docker.NetworkSettings.IPAddress", "value": "10.0.0.1"}
+				 ]}]},
+				{"id": "db.1", "name": "db", "slave_id": "s-0", "state": "TASK_RUNNING"}
+			]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig()
+	c.NameTemplates = []NameTemplate{
+		{"{name}.{label:team}.{discovery:environment}", TaskTarget},
+		{"{name}-{agent}.node", AgentTarget},
+	}
+	rg := NewRecordGenerator(0, WithConfig(c))
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"docker", "host"}, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		name string
+		want []string
+	}{
+		{"web.core.prod.mesos.", []string{"10.0.0.1"}},
+		{"web-0.node.mesos.", []string{"1.2.3.4"}},
+		{"db-0.node.mesos.", []string{"1.2.3.4"}},
+		{"web-" + hashString("web.1") + "-0.marathon.mesos.", []string{"10.0.0.1"}},
+		{"web.marathon.mesos.", nil},
+		{"db.marathon.mesos.", nil},
+		{"api.mesos.", []string{"10.0.0.1"}},
+	} {
+		if got := values(rg.Records, tt.name, dns.TypeA); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got %q, want %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_Visibility(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [
+				{"id": "fw.1", "name": "fw", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "discovery": {"name": "fw", "visibility": "FRAMEWORK"}},
+				{"id": "cl.1", "name": "cl", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "discovery": {"name": "cl", "visibility": "CLUSTER"}},
+				{"id": "ext.1", "name": "ext", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "discovery": {"name": "ext", "visibility": "EXTERNAL"}}
+			]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		enforce bool
+		name    string
+		want    []string
+	}{
+		{false, "fw.marathon.mesos.", []string{"1.2.3.4"}},
+		{false, "ext.marathon.example.com.", nil},
+		{true, "fw.marathon.mesos.", nil},
+		{true, "cl.marathon.mesos.", []string{"1.2.3.4"}},
+		{true, "cl.marathon.example.com.", nil},
+		{true, "ext.marathon.mesos.", []string{"1.2.3.4"}},
+		{true, "ext.marathon.example.com.", []string{"1.2.3.4"}},
+	} {
+		c := NewConfig()
+		c.EnforceVisibility = tt.enforce
+		c.ExternalDomain = "example.com"
+		rg := NewRecordGenerator(0, WithConfig(c))
+		if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+			t.Fatal(err)
+		}
+		if got := values(rg.Records, tt.name, dns.TypeA); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got %q, want %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_ExternalPTR(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [{
+				"id": "ext.1", "name": "ext", "slave_id": "s-0", "state": "TASK_RUNNING",
+				"discovery": {"name": "ext", "visibility": "EXTERNAL"},
+				"statuses": [{"state": "TASK_RUNNING", "container_status": {"network_infos": [
+					{"ip_addresses": [{"ip_address": "10.0.0.1"}]}
+				]}}]
+			}]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+	c := NewConfig()
+	c.EnforceVisibility = true
+	c.ExternalDomain = "example.com"
+	rg := NewRecordGenerator(0, WithConfig(c))
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"netinfo", "host"}, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	// the address maps back to the name in the domain only
+	got := values(rg.Records, "1.0.0.10.in-addr.arpa.", dns.TypePTR)
+	if len(got) != 1 || !strings.HasSuffix(got[0], ".marathon.mesos.") {
+		t.Errorf("got PTR %q, want one name in the domain", got)
+	}
+}
+
+func TestInsertState_TaskStates(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [
+				{"id": "run.1", "name": "run", "slave_id": "s-0", "state": "TASK_RUNNING"},
+				{"id": "kill.1", "name": "kill", "slave_id": "s-0", "state": "TASK_KILLING"},
+				{"id": "stage.1", "name": "stage", "slave_id": "s-0", "state": "TASK_STAGING"}
+			]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		states []string
+		want   map[string]bool
+	}{
+		{nil, map[string]bool{"run": true}},
+		{[]string{"TASK_RUNNING"}, map[string]bool{"run": true}},
+		{[]string{"TASK_RUNNING", "TASK_KILLING"}, map[string]bool{"run": true, "kill": true}},
+		{[]string{"TASK_STAGING"}, map[string]bool{"stage": true}},
+	} {
+		c := NewConfig()
+		c.TaskStates = tt.states
+		rg := NewRecordGenerator(0, WithConfig(c))
+		if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"run", "kill", "stage"} {
+			if got := rg.Records.Has(name + ".marathon.mesos."); got != tt.want[name] {
+				t.Errorf("test #%d: %s: got published %v, want %v", i, name, got, tt.want[name])
+			}
+		}
+	}
+}
+
+func TestInsertState_HealthPolicy(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [
+				{"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "statuses": [{"state": "TASK_RUNNING", "healthy": true,
+				   "container_status": {"network_infos": [{"ip_address": "10.0.0.1"}]}}]},
+				{"id": "web.2", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "statuses": [{"state": "TASK_RUNNING", "healthy": false,
+				   "container_status": {"network_infos": [{"ip_address": "10.0.0.2"}]}}]},
+				{"id": "db.1", "name": "db", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "statuses": [{"state": "TASK_RUNNING", "healthy": false,
+				   "container_status": {"network_infos": [{"ip_address": "10.0.0.3"}]}}]},
+				{"id": "cache.1", "name": "cache", "slave_id": "s-0", "state": "TASK_RUNNING",
+				 "statuses": [{"state": "TASK_RUNNING",
+				   "container_status": {"network_infos": [{"ip_address": "10.0.0.4"}]}}]}
+			]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []struct {
+		policy string
+		name   string
+		want   []string
+	}{
+		{AllPolicy, "web.marathon.mesos.", []string{"10.0.0.1", "10.0.0.2"}},
+		{AllPolicy, "db.marathon.mesos.", []string{"10.0.0.3"}},
+		{HealthyPolicy, "web.marathon.mesos.", []string{"10.0.0.1"}},
+		{HealthyPolicy, "db.marathon.mesos.", nil},
+		{HealthyPolicy, "cache.marathon.mesos.", []string{"10.0.0.4"}},
+		{HealthyFirstPolicy, "web.marathon.mesos.", []string{"10.0.0.1"}},
+		{HealthyFirstPolicy, "db.marathon.mesos.", []string{"10.0.0.3"}},
+		{HealthyFirstPolicy, "cache.marathon.mesos.", []string{"10.0.0.4"}},
+	} {
+		c := NewConfig()
+		c.HealthPolicy = tt.policy
+		rg := NewRecordGenerator(0, WithConfig(c))
+		if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"netinfo"}, labels.RFC1123); err != nil {
+			t.Fatal(err)
+		}
+		if got := values(rg.Records, tt.name, dns.TypeA); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %s %q: got %q, want %q", i, tt.policy, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInsertState_DiscoveryZones(t *testing.T) {
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{
+			"name": "marathon",
+			"tasks": [{
+				"id": "web.1", "name": "web", "slave_id": "s-0", "state": "TASK_RUNNING",
+				"discovery": {
+					"name": "web", "environment": "prod", "location": "useast1a", "version": "2",
+					"ports": {"ports": [{"number": 80, "protocol": "tcp"}]}
+				}
+			}]
+		}]
+	}`
+	var sj state.State
+	if err := json.Unmarshal([]byte(sjJSON), &sj); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConfig()
+	c.DiscoveryZones = []string{"environment", "location", "version"}
+	rg := NewRecordGenerator(0, WithConfig(c))
+	if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"host"}, labels.RFC1123); err != nil {
+		t.Fatal(err)
+	}
+
+	target := "web-" + hashString("web.1") + "-0.marathon.mesos.:80"
+	for i, tt := range []struct {
+		name  string
+		rtype uint16
+		want  []string
+	}{
+		{"web.prod.marathon.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{"web.useast1a.marathon.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{"v2.web.marathon.mesos.", dns.TypeA, []string{"1.2.3.4"}},
+		{"_web._tcp.prod.marathon.mesos.", dns.TypeSRV, []string{target}},
+		{"_web._tcp.useast1a.marathon.mesos.", dns.TypeSRV, []string{target}},
+		{"_web._tcp.v2.marathon.mesos.", dns.TypeSRV, []string{target}},
+		{"_web._udp.prod.marathon.mesos.", dns.TypeSRV, nil},
+	} {
+		if got := values(rg.Records, tt.name, tt.rtype); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: %q: got %q, want %q", i, tt.name, got, tt.want)
+		}
+	}
+}
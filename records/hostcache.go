@@ -0,0 +1,139 @@
+package records
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mesosphere/mesos-dns/logging"
+)
+
+// defaultHostLookupWorkers is the default number of concurrent hostname
+// lookups.
+const defaultHostLookupWorkers = 8
+
+// HostCache caches the IP addresses of hostnames across record generations.
+// Failed lookups are cached too, and if a hostname which resolved before
+// fails to resolve, its previous addresses keep being used for another TTL
+// so that a flapping resolver doesn't drop it. It's safe for concurrent use.
+type HostCache struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+	lookup      func(string) ([]net.IP, error)
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]hostEntry
+}
+
+// hostEntry is a cached lookup of a hostname.
+type hostEntry struct {
+	ips []net.IP
+	err error
+	// resolved is when the hostname last resolved successfully.
+	resolved time.Time
+	expires  time.Time
+}
+
+// NewHostCache returns a HostCache which keeps successful lookups for ttl and
+// failed ones for negativeTTL.
+func NewHostCache(ttl, negativeTTL time.Duration) *HostCache {
+	return &HostCache{
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		lookup:      net.LookupIP,
+		now:         time.Now,
+		entries:     map[string]hostEntry{},
+	}
+}
+
+// LookupIP returns the IP addresses of the given hostname, looking it up
+// unless a cached lookup hasn't expired yet.
+func (c *HostCache) LookupIP(host string) ([]net.IP, error) {
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	c.mu.Unlock()
+	now := c.now()
+	if ok && now.Before(e.expires) {
+		return e.ips, e.err
+	}
+
+	start := time.Now()
+	ips, err := c.lookup(host)
+	logging.CurLog.HostLookups.Inc()
+	logging.CurLog.HostLookupLatency.Observe(time.Since(start))
+
+	switch {
+	case err == nil:
+		e = hostEntry{ips: ips, resolved: now, expires: now.Add(c.ttl)}
+	case ok && len(e.ips) > 0 && now.Sub(e.resolved) < 2*c.ttl:
+		logging.CurLog.HostLookupFailures.Inc()
+		logging.Error.Printf("cannot resolve %q: %v; keeping its previous addresses", host, err)
+		e.err, e.expires = nil, now.Add(c.negativeTTL)
+	default:
+		logging.CurLog.HostLookupFailures.Inc()
+		e = hostEntry{err: err, expires: now.Add(c.negativeTTL)}
+	}
+
+	c.mu.Lock()
+	c.entries[host] = e
+	c.mu.Unlock()
+	return e.ips, e.err
+}
+
+// hostLookup is the result of the lookup of a hostname.
+type hostLookup struct {
+	ips []net.IP
+	err error
+}
+
+// lookupAll looks up the given hostnames concurrently with at most the given
+// number of workers, returning the results by hostname. Expired entries of
+// other hostnames, e.g. of agents which left the cluster, are then evicted.
+func (c *HostCache) lookupAll(hosts []string, workers int) map[string]hostLookup {
+	results := make(map[string]hostLookup, len(hosts))
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(hosts) {
+		workers = len(hosts)
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	ch := make(chan string)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for host := range ch {
+				ips, err := c.LookupIP(host)
+				mu.Lock()
+				results[host] = hostLookup{ips, err}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, host := range hosts {
+		ch <- host
+	}
+	close(ch)
+	wg.Wait()
+	c.sweep(results)
+	return results
+}
+
+// sweep evicts the expired entries of the hostnames which aren't in the given
+// lookups.
+func (c *HostCache) sweep(keep map[string]hostLookup) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for host, e := range c.entries {
+		if _, ok := keep[host]; !ok && !now.Before(e.expires) {
+			delete(c.entries, host)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package records
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostCache_LookupIP(t *testing.T) {
+	var (
+		now     = time.Unix(0, 0)
+		lookups int
+		fail    bool
+	)
+	c := NewHostCache(time.Minute, 10*time.Second)
+	c.now = func() time.Time { return now }
+	c.lookup = func(host string) ([]net.IP, error) {
+		lookups++
+		if fail {
+			return nil, errors.New("lookup failed")
+		}
+		return []net.IP{net.ParseIP("1.2.3.4")}, nil
+	}
+
+	for i, tt := range []struct {
+		at      time.Duration
+		fail    bool
+		want    []net.IP
+		err     bool
+		lookups int
+	}{
+		{0, false, []net.IP{net.ParseIP("1.2.3.4")}, false, 1},
+		{30 * time.Second, false, []net.IP{net.ParseIP("1.2.3.4")}, false, 1}, // cached
+		{60 * time.Second, true, []net.IP{net.ParseIP("1.2.3.4")}, false, 2},  // stale
+		{65 * time.Second, true, []net.IP{net.ParseIP("1.2.3.4")}, false, 2},  // negatively cached
+		{121 * time.Second, true, nil, true, 3},                               // stale for too long
+		{125 * time.Second, false, nil, true, 3},                              // negatively cached
+		{131 * time.Second, false, []net.IP{net.ParseIP("1.2.3.4")}, false, 4},
+	} {
+		now, fail = time.Unix(0, 0).Add(tt.at), tt.fail
+		ips, err := c.LookupIP("agent.example.com")
+		if !reflect.DeepEqual(ips, tt.want) || (err != nil) != tt.err {
+			t.Errorf("test #%d: got (%v, %v), want (%v, err: %v)", i, ips, err, tt.want, tt.err)
+		}
+		if lookups != tt.lookups {
+			t.Errorf("test #%d: got %d lookups, want %d", i, lookups, tt.lookups)
+		}
+	}
+}
+
+func TestHostCache_lookupAll(t *testing.T) {
+	var (
+		mu                  sync.Mutex
+		running, maxRunning int
+	)
+	c := NewHostCache(0, 0)
+	c.lookup = func(host string) ([]net.IP, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if host == "bad" {
+			return nil, errors.New("lookup failed")
+		}
+		return []net.IP{net.ParseIP("1.2.3.4")}, nil
+	}
+
+	hosts := []string{"a", "b", "c", "d", "e", "bad"}
+	results := c.lookupAll(hosts, 2)
+	if len(results) != len(hosts) {
+		t.Fatalf("got %d results, want %d", len(results), len(hosts))
+	}
+	for _, host := range hosts {
+		r := results[host]
+		if (r.err != nil) != (host == "bad") {
+			t.Errorf("%q: got err %v", host, r.err)
+		}
+	}
+	if maxRunning > 2 {
+		t.Errorf("got %d concurrent lookups, want at most 2", maxRunning)
+	}
+}
+
+func TestHostCache_sweep(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewHostCache(time.Minute, 10*time.Second)
+	c.now = func() time.Time { return now }
+	c.lookup = func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("1.2.3.4")}, nil
+	}
+
+	for i, tt := range []struct {
+		at    time.Duration
+		hosts []string
+		want  []string
+	}{
+		{0, []string{"a", "b"}, []string{"a", "b"}},
+		{30 * time.Second, []string{"a"}, []string{"a", "b"}}, // b not expired yet
+		{60 * time.Second, []string{"a"}, []string{"a"}},      // b expired
+		{180 * time.Second, nil, nil},
+	} {
+		now = time.Unix(0, 0).Add(tt.at)
+		c.lookupAll(tt.hosts, 1)
+		var got []string
+		for host := range c.entries {
+			got = append(got, host)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: got entries %v, want %v", i, got, tt.want)
+		}
+	}
+}
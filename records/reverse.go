@@ -0,0 +1,45 @@
+package records
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ReverseZone returns the in-addr.arpa or ip6.arpa zone name of the given
+// CIDR. IPv4 prefix lengths must be multiples of 8 and IPv6 prefix lengths
+// multiples of 4 so that they fall on label boundaries.
+func ReverseZone(cidr string) (string, error) {
+	ip, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := n.Mask.Size()
+	step := 4 // nibbles
+	if ip.To4() != nil {
+		step = 8 // octets
+	}
+	if ones%step != 0 {
+		return "", fmt.Errorf("prefix length of %q isn't a multiple of %d", cidr, step)
+	}
+	arpa, err := dns.ReverseAddr(n.IP.String())
+	if err != nil {
+		return "", err
+	}
+	labels := strings.Split(arpa, ".")
+	return strings.Join(labels[(bits-ones)/step:], "."), nil
+}
+
+// ReverseZones returns the reverse zone names of the given CIDRs, skipping
+// invalid ones.
+func ReverseZones(cidrs []string) []string {
+	zones := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if zone, err := ReverseZone(cidr); err == nil {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
@@ -0,0 +1,30 @@
+package records
+
+import (
+	"testing"
+)
+
+func TestReverseZone(t *testing.T) {
+	for i, tt := range []struct {
+		cidr, zone string
+		valid      bool
+	}{
+		{"10.0.0.0/8", "10.in-addr.arpa.", true},
+		{"10.1.2.0/24", "2.1.10.in-addr.arpa.", true},
+		{"10.1.2.3/16", "1.10.in-addr.arpa.", true},
+		{"0.0.0.0/0", "in-addr.arpa.", true},
+		{"10.1.2.3/32", "3.2.1.10.in-addr.arpa.", true},
+		{"fd00::/8", "d.f.ip6.arpa.", true},
+		{"2001:db8::/32", "8.b.d.0.1.0.0.2.ip6.arpa.", true},
+		{"172.16.0.0/12", "", false},
+		{"fd00::/7", "", false},
+		{"10.0.0.0", "", false},
+	} {
+		zone, err := ReverseZone(tt.cidr)
+		if (err == nil) != tt.valid {
+			t.Errorf("test #%d: ReverseZone(%q): got err %v, want valid %v", i, tt.cidr, err, tt.valid)
+		} else if zone != tt.zone {
+			t.Errorf("test #%d: ReverseZone(%q): got %q, want %q", i, tt.cidr, zone, tt.zone)
+		}
+	}
+}
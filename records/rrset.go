@@ -0,0 +1,208 @@
+package records
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// RR is a typed resource record along with metadata about where it came from.
+type RR struct {
+	Name string
+	// Type is the DNS type of the record, e.g. dns.TypeA.
+	Type uint16
+	// IP is the address of A and AAAA records.
+	IP net.IP
+	// Target is the target name of SRV, PTR, CNAME, MX and NS records.
+	Target string
+	// Port, Priority and Weight are the fields of SRV records. Priority is
+	// the preference of MX records too.
+	Port, Priority, Weight uint16
+	// Text is the character-string of TXT records.
+	Text string
+	// TTL overrides the configured TTL of the record if non-zero.
+	TTL uint32
+	// Class is the record class (e.g. TaskClass) of the record.
+	Class string
+	// Origin identifies the Mesos entity the record was generated from.
+	Origin Origin
+}
+
+// Origin identifies the Mesos task, framework and agent a record was
+// generated from. Fields which don't apply are empty.
+type Origin struct {
+	TaskID      string `json:",omitempty"`
+	FrameworkID string `json:",omitempty"`
+	AgentID     string `json:",omitempty"`
+}
+
+// Value returns the textual representation of the record's data: an IP
+// address for A and AAAA records, a "host:port" pair for SRV records, the
+// target name for PTR, CNAME and NS records, a "preference target" pair for
+// MX records and the text of TXT records.
+func (rr RR) Value() string {
+	switch rr.Type {
+	case dns.TypeA, dns.TypeAAAA:
+		return rr.IP.String()
+	case dns.TypeSRV:
+		return net.JoinHostPort(rr.Target, strconv.Itoa(int(rr.Port)))
+	case dns.TypePTR, dns.TypeCNAME, dns.TypeNS:
+		return rr.Target
+	case dns.TypeMX:
+		return strconv.Itoa(int(rr.Priority)) + " " + rr.Target
+	case dns.TypeTXT:
+		return rr.Text
+	default:
+		return ""
+	}
+}
+
+// String returns the textual representation of the record, e.g.
+// "_web._tcp.marathon.mesos. SRV web.marathon.mesos.:80".
+func (rr RR) String() string {
+	return rr.Name + " " + dns.TypeToString[rr.Type] + " " + rr.Value()
+}
+
+// rrKey indexes the records of a RecordSet.
+type rrKey struct {
+	name  string
+	rtype uint16
+}
+
+// RecordSet is a set of typed resource records indexed by name and type.
+// It's immutable once built: the returned slices must not be modified.
+type RecordSet struct {
+	rrs   map[rrKey][]RR
+	names map[string]int
+	seen  map[string]struct{}
+	size  int
+}
+
+// NewRecordSet returns a RecordSet holding the given records, dropping
+// duplicates of the same name, type and value.
+func NewRecordSet(rrs ...RR) *RecordSet {
+	rs := &RecordSet{
+		rrs:   make(map[rrKey][]RR, len(rrs)),
+		names: make(map[string]int, len(rrs)),
+		seen:  make(map[string]struct{}, len(rrs)),
+	}
+	for _, rr := range rrs {
+		rs.insert(rr)
+	}
+	return rs
+}
+
+// insert adds the given record unless a record with the same name, type and
+// value exists already. returns true if added, false otherwise. It must only
+// be called while building the set.
+func (rs *RecordSet) insert(rr RR) bool {
+	id := rr.Name + "\x00" + strconv.Itoa(int(rr.Type)) + "\x00" + rr.Value()
+	if _, ok := rs.seen[id]; ok {
+		return false
+	}
+	rs.seen[id] = struct{}{}
+	k := rrKey{rr.Name, rr.Type}
+	rs.rrs[k] = append(rs.rrs[k], rr)
+	rs.names[rr.Name]++
+	rs.size++
+	return true
+}
+
+// With returns a new RecordSet holding the records of the set along with the
+// given ones, dropping duplicates of the same name, type and value.
+func (rs *RecordSet) With(rrs ...RR) *RecordSet {
+	all := NewRecordSet()
+	if rs != nil {
+		for _, krrs := range rs.rrs {
+			for _, rr := range krrs {
+				all.insert(rr)
+			}
+		}
+	}
+	for _, rr := range rrs {
+		all.insert(rr)
+	}
+	return all
+}
+
+// lookup returns the record with the same name, type and value as rr, if any.
+func (rs *RecordSet) lookup(rr RR) (RR, bool) {
+	if rs == nil {
+		return RR{}, false
+	}
+	value := rr.Value()
+	for _, other := range rs.rrs[rrKey{rr.Name, rr.Type}] {
+		if other.Value() == value {
+			return other, true
+		}
+	}
+	return RR{}, false
+}
+
+// Get returns the records of the given name and type, in insertion order.
+func (rs *RecordSet) Get(name string, rtype uint16) []RR {
+	if rs == nil {
+		return nil
+	}
+	return rs.rrs[rrKey{name, rtype}]
+}
+
+// maxCNAMEChain is the maximum number of CNAME records followed by Follow.
+const maxCNAMEChain = 8
+
+// Follow returns the name the given one is an alias of, following its CNAME
+// records, along with the CNAME records followed. Names with several CNAME
+// records are resolved with the first one. Chains longer than maxCNAMEChain
+// records, e.g. loops, are cut.
+func (rs *RecordSet) Follow(name string) (string, []RR) {
+	var chain []RR
+	for len(chain) < maxCNAMEChain {
+		cnames := rs.Get(name, dns.TypeCNAME)
+		if len(cnames) == 0 {
+			break
+		}
+		chain = append(chain, cnames[0])
+		name = cnames[0].Target
+	}
+	return name, chain
+}
+
+// Has returns whether any record of the given name exists.
+func (rs *RecordSet) Has(name string) bool {
+	return rs != nil && rs.names[name] > 0
+}
+
+// Len returns the number of records in the set.
+func (rs *RecordSet) Len() int {
+	if rs == nil {
+		return 0
+	}
+	return rs.size
+}
+
+// Count returns the number of distinct names with records of the given type.
+func (rs *RecordSet) Count(rtype uint16) int {
+	if rs == nil {
+		return 0
+	}
+	n := 0
+	for k := range rs.rrs {
+		if k.rtype == rtype {
+			n++
+		}
+	}
+	return n
+}
+
+// All returns all the records in the set, in no particular order.
+func (rs *RecordSet) All() []RR {
+	if rs == nil {
+		return nil
+	}
+	all := make([]RR, 0, rs.size)
+	for _, rrs := range rs.rrs {
+		all = append(all, rrs...)
+	}
+	return all
+}
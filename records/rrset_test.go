@@ -0,0 +1,92 @@
+package records
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestRecordSet(t *testing.T) {
+	rs := NewRecordSet(
+		RR{Name: "a.mesos.", Type: dns.TypeA, IP: net.ParseIP("1.2.3.4").To4()},
+		RR{Name: "a.mesos.", Type: dns.TypeA, IP: net.ParseIP("1.2.3.4").To4()},
+		RR{Name: "a.mesos.", Type: dns.TypeA, IP: net.ParseIP("1.2.3.5").To4()},
+		RR{Name: "a.mesos.", Type: dns.TypeAAAA, IP: net.ParseIP("fd00::1")},
+		RR{Name: "_a._tcp.mesos.", Type: dns.TypeSRV, Target: "a.mesos.", Port: 80},
+		RR{Name: "_a._tcp.mesos.", Type: dns.TypeSRV, Target: "a.mesos.", Port: 80, Weight: 1},
+		RR{Name: "a.mesos.", Type: dns.TypeTXT, Text: "k=v"},
+	)
+
+	if got, want := rs.Len(), 5; got != want {
+		t.Errorf("Len: got %d, want %d", got, want)
+	}
+	for i, tt := range []struct {
+		name  string
+		rtype uint16
+		want  []string
+	}{
+		{"a.mesos.", dns.TypeA, []string{"1.2.3.4", "1.2.3.5"}},
+		{"a.mesos.", dns.TypeAAAA, []string{"fd00::1"}},
+		{"a.mesos.", dns.TypeTXT, []string{"k=v"}},
+		{"a.mesos.", dns.TypeSRV, nil},
+		{"_a._tcp.mesos.", dns.TypeSRV, []string{"a.mesos.:80"}},
+		{"b.mesos.", dns.TypeA, nil},
+	} {
+		if got := values(rs, tt.name, tt.rtype); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: Get(%q, %s): got %q, want %q", i, tt.name, dns.TypeToString[tt.rtype], got, tt.want)
+		}
+	}
+	if !rs.Has("_a._tcp.mesos.") || rs.Has("b.mesos.") {
+		t.Error("Has: got wrong result")
+	}
+
+	with := rs.With(
+		RR{Name: "a.mesos.", Type: dns.TypeA, IP: net.ParseIP("1.2.3.5").To4()},
+		RR{Name: "a.mesos.", Type: dns.TypeA, IP: net.ParseIP("1.2.3.6").To4()},
+	)
+	if got, want := values(with, "a.mesos.", dns.TypeA), []string{"1.2.3.4", "1.2.3.5", "1.2.3.6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("With: got %q, want %q", got, want)
+	}
+	if rs.Len() != 5 || with.Len() != 6 {
+		t.Errorf("With: got lengths %d and %d, want 5 and 6", rs.Len(), with.Len())
+	}
+
+	var nilSet *RecordSet
+	if nilSet.Get("a.mesos.", dns.TypeA) != nil || nilSet.Has("a.mesos.") || nilSet.Len() != 0 {
+		t.Error("nil RecordSet isn't empty")
+	}
+}
+
+func TestRecordSet_Follow(t *testing.T) {
+	rs := NewRecordSet(
+		RR{Name: "api.mesos.", Type: dns.TypeCNAME, Target: "web.mesos."},
+		RR{Name: "www.mesos.", Type: dns.TypeCNAME, Target: "api.mesos."},
+		RR{Name: "web.mesos.", Type: dns.TypeA, IP: net.ParseIP("1.2.3.4").To4()},
+		RR{Name: "loop.mesos.", Type: dns.TypeCNAME, Target: "loop.mesos."},
+	)
+	for i, tt := range []struct {
+		name, want string
+		chain      int
+	}{
+		{"web.mesos.", "web.mesos.", 0},
+		{"api.mesos.", "web.mesos.", 1},
+		{"www.mesos.", "web.mesos.", 2},
+		{"loop.mesos.", "loop.mesos.", maxCNAMEChain},
+		{"none.mesos.", "none.mesos.", 0},
+	} {
+		if got, chain := rs.Follow(tt.name); got != tt.want || len(chain) != tt.chain {
+			t.Errorf("test #%d: Follow(%q): got (%q, %d records), want (%q, %d records)", i, tt.name, got, len(chain), tt.want, tt.chain)
+		}
+	}
+}
+
+// values returns the values of the records of the given name and type.
+func values(rs *RecordSet, name string, rtype uint16) []string {
+	var vs []string
+	for _, rr := range rs.Get(name, rtype) {
+		vs = append(vs, rr.Value())
+	}
+	return vs
+}
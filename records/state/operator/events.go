@@ -0,0 +1,286 @@
+package operator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// EventType is the type of an event of the SUBSCRIBE event stream.
+type EventType int32
+
+// Event types of the SUBSCRIBE event stream.
+const (
+	EventUnknown          EventType = 0
+	EventSubscribed       EventType = 1
+	EventTaskAdded        EventType = 2
+	EventTaskUpdated      EventType = 3
+	EventAgentAdded       EventType = 4
+	EventAgentRemoved     EventType = 5
+	EventFrameworkAdded   EventType = 6
+	EventFrameworkUpdated EventType = 7
+	EventFrameworkRemoved EventType = 8
+	EventHeartbeat        EventType = 9
+)
+
+var eventTypeNames = map[EventType]string{
+	EventUnknown:          "UNKNOWN",
+	EventSubscribed:       "SUBSCRIBED",
+	EventTaskAdded:        "TASK_ADDED",
+	EventTaskUpdated:      "TASK_UPDATED",
+	EventAgentAdded:       "AGENT_ADDED",
+	EventAgentRemoved:     "AGENT_REMOVED",
+	EventFrameworkAdded:   "FRAMEWORK_ADDED",
+	EventFrameworkUpdated: "FRAMEWORK_UPDATED",
+	EventFrameworkRemoved: "FRAMEWORK_REMOVED",
+	EventHeartbeat:        "HEARTBEAT",
+}
+
+// String returns the name of the event type, e.g. "TASK_ADDED".
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return strconv.Itoa(int(t))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for EventTypes.
+func (t *EventType) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalEnum(data, int32(EventUnknown), func(name string) (int32, bool) {
+		for et, n := range eventTypeNames {
+			if n == name {
+				return int32(et), true
+			}
+		}
+		return 0, false
+	})
+	*t = EventType(v)
+	return err
+}
+
+// Event is an event of the SUBSCRIBE event stream. Only the field of its
+// type is set.
+type Event struct {
+	Type             EventType         `protobuf:"varint,1,opt,name=type" json:"type"`
+	Subscribed       *Subscribed       `protobuf:"bytes,2,opt,name=subscribed" json:"subscribed,omitempty"`
+	TaskAdded        *TaskAdded        `protobuf:"bytes,3,opt,name=task_added" json:"task_added,omitempty"`
+	TaskUpdated      *TaskUpdated      `protobuf:"bytes,4,opt,name=task_updated" json:"task_updated,omitempty"`
+	AgentAdded       *AgentAdded       `protobuf:"bytes,5,opt,name=agent_added" json:"agent_added,omitempty"`
+	AgentRemoved     *AgentRemoved     `protobuf:"bytes,6,opt,name=agent_removed" json:"agent_removed,omitempty"`
+	FrameworkAdded   *FrameworkAdded   `protobuf:"bytes,7,opt,name=framework_added" json:"framework_added,omitempty"`
+	FrameworkUpdated *FrameworkUpdated `protobuf:"bytes,8,opt,name=framework_updated" json:"framework_updated,omitempty"`
+	FrameworkRemoved *FrameworkRemoved `protobuf:"bytes,9,opt,name=framework_removed" json:"framework_removed,omitempty"`
+}
+
+// Reset implements the proto.Message interface.
+func (e *Event) Reset() { *e = Event{} }
+
+// String implements the proto.Message interface.
+func (e *Event) String() string { return proto.CompactTextString(e) }
+
+// ProtoMessage implements the proto.Message interface.
+func (*Event) ProtoMessage() {}
+
+// Subscribed is the first event of the stream: the state of the cluster the
+// following events apply to.
+type Subscribed struct {
+	GetState                 *GetStateResponse `protobuf:"bytes,1,opt,name=get_state" json:"get_state,omitempty"`
+	HeartbeatIntervalSeconds float64           `protobuf:"fixed64,2,opt,name=heartbeat_interval_seconds" json:"heartbeat_interval_seconds,omitempty"`
+}
+
+// TaskAdded holds a task launched by a framework.
+type TaskAdded struct {
+	Task Task `protobuf:"bytes,1,opt,name=task" json:"task"`
+}
+
+// TaskUpdated holds a status update of a task.
+type TaskUpdated struct {
+	FrameworkID ID         `protobuf:"bytes,1,opt,name=framework_id" json:"framework_id"`
+	Status      TaskStatus `protobuf:"bytes,2,opt,name=status" json:"status"`
+	State       TaskState  `protobuf:"varint,3,opt,name=state" json:"state"`
+}
+
+// AgentAdded holds an agent which registered with the master.
+type AgentAdded struct {
+	Agent Agent `protobuf:"bytes,1,opt,name=agent" json:"agent"`
+}
+
+// AgentRemoved holds the ID of an agent removed from the cluster.
+type AgentRemoved struct {
+	AgentID ID `protobuf:"bytes,1,opt,name=agent_id" json:"agent_id"`
+}
+
+// FrameworkAdded holds a framework which registered with the master.
+type FrameworkAdded struct {
+	Framework Framework `protobuf:"bytes,1,opt,name=framework" json:"framework"`
+}
+
+// FrameworkUpdated holds a framework whose information changed.
+type FrameworkUpdated struct {
+	Framework Framework `protobuf:"bytes,1,opt,name=framework" json:"framework"`
+}
+
+// FrameworkRemoved holds a framework removed from the cluster.
+type FrameworkRemoved struct {
+	FrameworkInfo FrameworkInfo `protobuf:"bytes,1,opt,name=framework_info" json:"framework_info"`
+}
+
+// listed reports whether tasks in the given state are listed among the tasks
+// of GET_STATE responses, rather than among their completed or unreachable
+// tasks.
+func (s TaskState) listed() bool {
+	switch s.String() {
+	case "TASK_FINISHED", "TASK_FAILED", "TASK_KILLED", "TASK_LOST", "TASK_ERROR",
+		"TASK_DROPPED", "TASK_UNREACHABLE", "TASK_GONE", "TASK_GONE_BY_OPERATOR":
+		return false
+	}
+	return true
+}
+
+// Apply updates the state with the given event. SUBSCRIBED events replace
+// the whole state, and events of unknown types or about unknown tasks, agents
+// or frameworks are ignored.
+func (r *GetStateResponse) Apply(e *Event) {
+	switch {
+	case e.Subscribed != nil:
+		if e.Subscribed.GetState != nil {
+			*r = *e.Subscribed.GetState
+		}
+	case e.TaskAdded != nil:
+		r.removeTasks(func(t Task) bool { return t.same(e.TaskAdded.Task) })
+		r.GetTasks.Tasks = append(r.GetTasks.Tasks, e.TaskAdded.Task)
+	case e.TaskUpdated != nil:
+		r.updateTask(e.TaskUpdated)
+	case e.AgentAdded != nil:
+		r.removeAgent(e.AgentAdded.Agent.AgentInfo.ID)
+		r.GetAgents.Agents = append(r.GetAgents.Agents, e.AgentAdded.Agent)
+	case e.AgentRemoved != nil:
+		r.removeAgent(e.AgentRemoved.AgentID)
+	case e.FrameworkAdded != nil:
+		r.putFramework(e.FrameworkAdded.Framework)
+	case e.FrameworkUpdated != nil:
+		r.putFramework(e.FrameworkUpdated.Framework)
+	case e.FrameworkRemoved != nil:
+		id := e.FrameworkRemoved.FrameworkInfo.ID
+		r.removeFramework(id)
+		r.removeTasks(func(t Task) bool { return t.FrameworkID == id })
+	}
+}
+
+// updateTask applies the given status update to its task, dropping the task
+// once it stops being listed. Statuses of the task's current state are
+// replaced rather than appended, so that periodic updates such as health
+// checks don't grow them.
+func (r *GetStateResponse) updateTask(u *TaskUpdated) {
+	if !u.State.listed() {
+		r.removeTasks(func(t Task) bool { return t.FrameworkID == u.FrameworkID && t.TaskID == u.Status.TaskID })
+		return
+	}
+	for i := range r.GetTasks.Tasks {
+		t := &r.GetTasks.Tasks[i]
+		if t.FrameworkID != u.FrameworkID || t.TaskID != u.Status.TaskID {
+			continue
+		}
+		t.State = u.State
+		if n := len(t.Statuses); n > 0 && t.Statuses[n-1].State == u.Status.State {
+			t.Statuses[n-1] = u.Status
+		} else {
+			t.Statuses = append(t.Statuses, u.Status)
+		}
+		return
+	}
+}
+
+// same reports whether both tasks have the same framework and task IDs.
+func (t Task) same(u Task) bool {
+	return t.FrameworkID == u.FrameworkID && t.TaskID == u.TaskID
+}
+
+// removeTasks removes the tasks matching the given predicate.
+func (r *GetStateResponse) removeTasks(match func(Task) bool) {
+	tasks := r.GetTasks.Tasks[:0]
+	for _, t := range r.GetTasks.Tasks {
+		if !match(t) {
+			tasks = append(tasks, t)
+		}
+	}
+	r.GetTasks.Tasks = tasks
+}
+
+// removeAgent removes the agent with the given ID, if any.
+func (r *GetStateResponse) removeAgent(id ID) {
+	agents := r.GetAgents.Agents[:0]
+	for _, a := range r.GetAgents.Agents {
+		if a.AgentInfo.ID != id {
+			agents = append(agents, a)
+		}
+	}
+	r.GetAgents.Agents = agents
+}
+
+// putFramework adds the given framework, replacing the one with its ID.
+func (r *GetStateResponse) putFramework(f Framework) {
+	for i := range r.GetFrameworks.Frameworks {
+		if r.GetFrameworks.Frameworks[i].FrameworkInfo.ID == f.FrameworkInfo.ID {
+			r.GetFrameworks.Frameworks[i] = f
+			return
+		}
+	}
+	r.GetFrameworks.Frameworks = append(r.GetFrameworks.Frameworks, f)
+}
+
+// removeFramework removes the framework with the given ID, if any.
+func (r *GetStateResponse) removeFramework(id ID) {
+	frameworks := r.GetFrameworks.Frameworks[:0]
+	for _, f := range r.GetFrameworks.Frameworks {
+		if f.FrameworkInfo.ID != id {
+			frameworks = append(frameworks, f)
+		}
+	}
+	r.GetFrameworks.Frameworks = frameworks
+}
+
+// RecordIOReader reads the records of a RecordIO stream, the framing of the
+// events of the SUBSCRIBE call: each record is preceded by its length in
+// bytes, in decimal, and a newline.
+type RecordIOReader struct {
+	r *bufio.Reader
+}
+
+// NewRecordIOReader returns a RecordIOReader reading from the given reader.
+func NewRecordIOReader(r io.Reader) *RecordIOReader {
+	return &RecordIOReader{r: bufio.NewReader(r)}
+}
+
+// ReadRecord returns the next record of the stream, or io.EOF at its end.
+func (r *RecordIOReader) ReadRecord() ([]byte, error) {
+	line, err := r.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(line), 10, 31)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RecordIO record length %q", strings.TrimSpace(line))
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// Buffered returns the number of bytes already read from the stream but not
+// returned as records yet. Zero means that the next record hasn't arrived
+// yet, or only partly.
+func (r *RecordIOReader) Buffered() int {
+	return r.r.Buffered()
+}
@@ -0,0 +1,140 @@
+package operator
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetStateResponse_Apply(t *testing.T) {
+	task := func(fw, id string, state TaskState) Task {
+		return Task{Name: id, TaskID: ID{id}, FrameworkID: ID{fw}, AgentID: ID{"s0"}, State: state}
+	}
+	status := func(id string, state TaskState, ts float64) TaskStatus {
+		return TaskStatus{TaskID: ID{id}, State: state, Timestamp: ts}
+	}
+	framework := func(id, name string) Framework {
+		return Framework{FrameworkInfo: FrameworkInfo{ID: ID{id}, Name: name}}
+	}
+	agent := func(id string) Agent {
+		return Agent{AgentInfo: AgentInfo{ID: ID{id}, Hostname: id}}
+	}
+
+	var r GetStateResponse
+	for i, e := range []Event{
+		{Type: EventSubscribed, Subscribed: &Subscribed{GetState: &GetStateResponse{
+			GetTasks:      GetTasksResponse{Tasks: []Task{task("fw0", "a", 1)}},
+			GetFrameworks: GetFrameworksResponse{Frameworks: []Framework{framework("fw0", "marathon")}},
+			GetAgents:     GetAgentsResponse{Agents: []Agent{agent("s0")}},
+		}}},
+		{Type: EventHeartbeat},
+		{Type: EventFrameworkAdded, FrameworkAdded: &FrameworkAdded{Framework: framework("fw1", "chronos")}},
+		{Type: EventFrameworkUpdated, FrameworkUpdated: &FrameworkUpdated{Framework: framework("fw0", "marathon-prod")}},
+		{Type: EventTaskAdded, TaskAdded: &TaskAdded{Task: task("fw0", "b", 6)}},
+		{Type: EventTaskAdded, TaskAdded: &TaskAdded{Task: task("fw1", "c", 6)}},
+		{Type: EventTaskUpdated, TaskUpdated: &TaskUpdated{FrameworkID: ID{"fw0"}, Status: status("b", 1, 1), State: 1}},
+		{Type: EventTaskUpdated, TaskUpdated: &TaskUpdated{FrameworkID: ID{"fw0"}, Status: status("b", 1, 2), State: 1}},
+		{Type: EventTaskUpdated, TaskUpdated: &TaskUpdated{FrameworkID: ID{"fw0"}, Status: status("a", 2, 3), State: 2}},
+		{Type: EventTaskUpdated, TaskUpdated: &TaskUpdated{FrameworkID: ID{"fw0"}, Status: status("x", 1, 3), State: 1}},
+		{Type: EventAgentAdded, AgentAdded: &AgentAdded{Agent: agent("s1")}},
+		{Type: EventAgentAdded, AgentAdded: &AgentAdded{Agent: agent("s2")}},
+		{Type: EventAgentRemoved, AgentRemoved: &AgentRemoved{AgentID: ID{"s0"}}},
+		{Type: EventFrameworkRemoved, FrameworkRemoved: &FrameworkRemoved{FrameworkInfo: FrameworkInfo{ID: ID{"fw1"}}}},
+	} {
+		e := e
+		r.Apply(&e)
+		if i == 0 && len(r.GetTasks.Tasks) != 1 {
+			t.Fatalf("SUBSCRIBED: got state %+v", r)
+		}
+	}
+
+	b := task("fw0", "b", 1)
+	b.Statuses = []TaskStatus{status("b", 1, 2)}
+	want := GetStateResponse{
+		GetTasks:      GetTasksResponse{Tasks: []Task{b}},
+		GetFrameworks: GetFrameworksResponse{Frameworks: []Framework{framework("fw0", "marathon-prod")}},
+		GetAgents:     GetAgentsResponse{Agents: []Agent{agent("s1"), agent("s2")}},
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("got state\n%+v\nwant\n%+v", r, want)
+	}
+}
+
+func TestEvent_JSON(t *testing.T) {
+	var e Event
+	const data = `{"type": "TASK_UPDATED", "task_updated": {
+		"framework_id": {"value": "fw0"},
+		"status": {"task_id": {"value": "a"}, "state": "TASK_RUNNING", "timestamp": 1},
+		"state": "TASK_RUNNING"
+	}}`
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		t.Fatal(err)
+	}
+	want := Event{Type: EventTaskUpdated, TaskUpdated: &TaskUpdated{
+		FrameworkID: ID{"fw0"},
+		Status:      TaskStatus{TaskID: ID{"a"}, State: 1, Timestamp: 1},
+		State:       1,
+	}}
+	if !reflect.DeepEqual(e, want) {
+		t.Errorf("got event %+v, want %+v", e, want)
+	}
+}
+
+func TestEvent_JSON_Unknown(t *testing.T) {
+	for i, tt := range []struct {
+		data string
+		want Event
+	}{
+		{`{"type": "AGENT_UPDATED", "agent_updated": {"agent": {}}}`, Event{Type: EventUnknown}},
+		{`{"type": "TASK_UPDATED", "task_updated": {
+			"framework_id": {"value": "fw0"},
+			"status": {"task_id": {"value": "a"}, "state": "TASK_NEW"},
+			"state": "TASK_NEW"
+		}}`, Event{Type: EventTaskUpdated, TaskUpdated: &TaskUpdated{
+			FrameworkID: ID{"fw0"},
+			Status:      TaskStatus{TaskID: ID{"a"}, State: taskUnknown},
+			State:       taskUnknown,
+		}}},
+		{`{"type": "TASK_ADDED", "task_added": {"task": {
+			"discovery": {"visibility": "INTERNAL"}
+		}}}`, Event{Type: EventTaskAdded, TaskAdded: &TaskAdded{Task: Task{
+			Discovery: &DiscoveryInfo{},
+		}}}},
+	} {
+		var e Event
+		if err := json.Unmarshal([]byte(tt.data), &e); err != nil {
+			t.Errorf("test #%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(e, tt.want) {
+			t.Errorf("test #%d: got event %+v, want %+v", i, e, tt.want)
+		}
+	}
+
+	var r GetStateResponse
+	r.Apply(&Event{Type: EventUnknown})
+	if !reflect.DeepEqual(r, GetStateResponse{}) {
+		t.Errorf("UNKNOWN: got state %+v", r)
+	}
+}
+
+func TestRecordIOReader(t *testing.T) {
+	r := NewRecordIOReader(strings.NewReader("5\nhello0\n3\nabc2\nx"))
+	for _, want := range []string{"hello", "", "abc"} {
+		data, err := r.ReadRecord()
+		if err != nil || string(data) != want {
+			t.Fatalf("got record %q (err %v), want %q", data, err, want)
+		}
+	}
+	if _, err := r.ReadRecord(); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated record: got err %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if _, err := NewRecordIOReader(strings.NewReader("")).ReadRecord(); err != io.EOF {
+		t.Errorf("empty stream: got err %v, want %v", err, io.EOF)
+	}
+	if _, err := NewRecordIOReader(strings.NewReader("x\n")).ReadRecord(); err == nil {
+		t.Error("invalid length: got no error")
+	}
+}
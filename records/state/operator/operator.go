@@ -0,0 +1,547 @@
+// Package operator implements the subset of the Mesos v1 operator API
+// (POST /api/v1) needed to read the state of a cluster: the GET_STATE,
+// GET_MASTER and SUBSCRIBE calls, in both their JSON and protobuf encodings.
+// Messages only declare the fields mesos-dns uses; the others are skipped
+// when decoding.
+package operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/mesos/mesos-go/upid"
+	"github.com/mesosphere/mesos-dns/records/state"
+)
+
+// Path is the path of the operator API endpoint of Mesos masters.
+const Path = "/api/v1"
+
+// Content types of the encodings of calls and responses.
+const (
+	JSONContentType     = "application/json"
+	ProtobufContentType = "application/x-protobuf"
+)
+
+// CallType is the type of an operator API call.
+type CallType int32
+
+// Call types used by mesos-dns.
+const (
+	GetState  CallType = 9
+	GetMaster CallType = 17
+	Subscribe CallType = 18
+)
+
+var callTypeNames = map[CallType]string{
+	GetState:  "GET_STATE",
+	GetMaster: "GET_MASTER",
+	Subscribe: "SUBSCRIBE",
+}
+
+// String returns the name of the call type, e.g. "GET_STATE".
+func (t CallType) String() string {
+	if name, ok := callTypeNames[t]; ok {
+		return name
+	}
+	return strconv.Itoa(int(t))
+}
+
+// MarshalJSON implements the json.Marshaler interface for CallTypes.
+func (t CallType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for CallTypes.
+func (t *CallType) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalEnum(data, 0, func(name string) (int32, bool) {
+		for ct, n := range callTypeNames {
+			if n == name {
+				return int32(ct), true
+			}
+		}
+		return 0, false
+	})
+	*t = CallType(v)
+	return err
+}
+
+// Call is an operator API call.
+type Call struct {
+	Type CallType `protobuf:"varint,1,opt,name=type" json:"type"`
+}
+
+// Reset implements the proto.Message interface.
+func (c *Call) Reset() { *c = Call{} }
+
+// String implements the proto.Message interface.
+func (c *Call) String() string { return proto.CompactTextString(c) }
+
+// ProtoMessage implements the proto.Message interface.
+func (*Call) ProtoMessage() {}
+
+// Response is the response to an operator API call.
+type Response struct {
+	GetState  *GetStateResponse  `protobuf:"bytes,9,opt,name=get_state" json:"get_state,omitempty"`
+	GetMaster *GetMasterResponse `protobuf:"bytes,16,opt,name=get_master" json:"get_master,omitempty"`
+}
+
+// Reset implements the proto.Message interface.
+func (r *Response) Reset() { *r = Response{} }
+
+// String implements the proto.Message interface.
+func (r *Response) String() string { return proto.CompactTextString(r) }
+
+// ProtoMessage implements the proto.Message interface.
+func (*Response) ProtoMessage() {}
+
+// GetStateResponse holds the state of the cluster.
+type GetStateResponse struct {
+	GetTasks      GetTasksResponse      `protobuf:"bytes,1,opt,name=get_tasks" json:"get_tasks"`
+	GetFrameworks GetFrameworksResponse `protobuf:"bytes,3,opt,name=get_frameworks" json:"get_frameworks"`
+	GetAgents     GetAgentsResponse     `protobuf:"bytes,4,opt,name=get_agents" json:"get_agents"`
+}
+
+// GetTasksResponse holds the tasks known to the master.
+type GetTasksResponse struct {
+	Tasks []Task `protobuf:"bytes,2,rep,name=tasks" json:"tasks"`
+}
+
+// GetFrameworksResponse holds the frameworks known to the master.
+type GetFrameworksResponse struct {
+	Frameworks []Framework `protobuf:"bytes,1,rep,name=frameworks" json:"frameworks"`
+}
+
+// GetAgentsResponse holds the agents registered with the master.
+type GetAgentsResponse struct {
+	Agents []Agent `protobuf:"bytes,1,rep,name=agents" json:"agents"`
+}
+
+// GetMasterResponse holds information about the master.
+type GetMasterResponse struct {
+	MasterInfo MasterInfo `protobuf:"bytes,1,opt,name=master_info" json:"master_info"`
+}
+
+// ID holds the value of a framework, agent or task ID.
+type ID struct {
+	Value string `protobuf:"bytes,1,opt,name=value" json:"value"`
+}
+
+// MasterInfo describes a master.
+type MasterInfo struct {
+	ID string `protobuf:"bytes,1,opt,name=id" json:"id"`
+	// IP is the IPv4 address of the master, in network order.
+	IP       uint32   `protobuf:"varint,2,opt,name=ip" json:"ip"`
+	Port     uint32   `protobuf:"varint,3,opt,name=port" json:"port"`
+	PID      string   `protobuf:"bytes,4,opt,name=pid" json:"pid,omitempty"`
+	Hostname string   `protobuf:"bytes,5,opt,name=hostname" json:"hostname,omitempty"`
+	Address  *Address `protobuf:"bytes,7,opt,name=address" json:"address,omitempty"`
+}
+
+// Address is a network address.
+type Address struct {
+	Hostname string `protobuf:"bytes,1,opt,name=hostname" json:"hostname,omitempty"`
+	IP       string `protobuf:"bytes,2,opt,name=ip" json:"ip,omitempty"`
+	Port     int32  `protobuf:"varint,3,opt,name=port" json:"port"`
+}
+
+// Framework is a framework known to the master.
+type Framework struct {
+	FrameworkInfo FrameworkInfo `protobuf:"bytes,1,opt,name=framework_info" json:"framework_info"`
+}
+
+// FrameworkInfo describes a framework.
+type FrameworkInfo struct {
+	Name     string   `protobuf:"bytes,2,opt,name=name" json:"name"`
+	ID       ID       `protobuf:"bytes,3,opt,name=id" json:"id"`
+	Role     string   `protobuf:"bytes,6,opt,name=role" json:"role,omitempty"`
+	Hostname string   `protobuf:"bytes,7,opt,name=hostname" json:"hostname,omitempty"`
+	WebUIURL string   `protobuf:"bytes,9,opt,name=webui_url" json:"webui_url,omitempty"`
+	Roles    []string `protobuf:"bytes,12,rep,name=roles" json:"roles,omitempty"`
+}
+
+// host returns the host of the framework's scheduler: its hostname if set, or
+// else the host of its web UI URL, if any.
+func (fi FrameworkInfo) host() string {
+	if fi.Hostname != "" || fi.WebUIURL == "" {
+		return fi.Hostname
+	}
+	u, err := url.Parse(fi.WebUIURL)
+	if err != nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(u.Host); err == nil {
+		return host
+	}
+	return strings.Trim(u.Host, "[]")
+}
+
+// Agent is an agent registered with the master.
+type Agent struct {
+	AgentInfo AgentInfo `protobuf:"bytes,1,opt,name=agent_info" json:"agent_info"`
+	PID       string    `protobuf:"bytes,4,opt,name=pid" json:"pid,omitempty"`
+}
+
+// AgentInfo describes an agent.
+type AgentInfo struct {
+	Hostname   string      `protobuf:"bytes,1,opt,name=hostname" json:"hostname"`
+	Attributes []Attribute `protobuf:"bytes,5,rep,name=attributes" json:"attributes,omitempty"`
+	ID         ID          `protobuf:"bytes,6,opt,name=id" json:"id"`
+	Port       int32       `protobuf:"varint,8,opt,name=port" json:"port,omitempty"`
+}
+
+// Attribute is an attribute of an agent. Only scalar and text attributes
+// are supported.
+type Attribute struct {
+	Name   string  `protobuf:"bytes,1,opt,name=name" json:"name"`
+	Scalar *Scalar `protobuf:"bytes,3,opt,name=scalar" json:"scalar,omitempty"`
+	Text   *Text   `protobuf:"bytes,5,opt,name=text" json:"text,omitempty"`
+}
+
+// Scalar is a scalar value.
+type Scalar struct {
+	Value float64 `protobuf:"fixed64,1,opt,name=value" json:"value"`
+}
+
+// Text is a text value.
+type Text struct {
+	Value string `protobuf:"bytes,1,opt,name=value" json:"value"`
+}
+
+// Ranges is a ranges value.
+type Ranges struct {
+	Range []Range `protobuf:"bytes,1,rep,name=range" json:"range"`
+}
+
+// Range is an inclusive range of integers.
+type Range struct {
+	Begin uint64 `protobuf:"varint,1,opt,name=begin" json:"begin"`
+	End   uint64 `protobuf:"varint,2,opt,name=end" json:"end"`
+}
+
+// Resource is a resource of a task. Only scalar and ranges resources are
+// supported.
+type Resource struct {
+	Name   string  `protobuf:"bytes,1,opt,name=name" json:"name"`
+	Scalar *Scalar `protobuf:"bytes,3,opt,name=scalar" json:"scalar,omitempty"`
+	Ranges *Ranges `protobuf:"bytes,4,opt,name=ranges" json:"ranges,omitempty"`
+}
+
+// Task is a task known to the master.
+type Task struct {
+	Name        string         `protobuf:"bytes,1,opt,name=name" json:"name"`
+	TaskID      ID             `protobuf:"bytes,2,opt,name=task_id" json:"task_id"`
+	FrameworkID ID             `protobuf:"bytes,3,opt,name=framework_id" json:"framework_id"`
+	AgentID     ID             `protobuf:"bytes,5,opt,name=agent_id" json:"agent_id"`
+	State       TaskState      `protobuf:"varint,6,opt,name=state" json:"state"`
+	Resources   []Resource     `protobuf:"bytes,7,rep,name=resources" json:"resources,omitempty"`
+	Statuses    []TaskStatus   `protobuf:"bytes,8,rep,name=statuses" json:"statuses,omitempty"`
+	Labels      Labels         `protobuf:"bytes,11,opt,name=labels" json:"labels"`
+	Discovery   *DiscoveryInfo `protobuf:"bytes,12,opt,name=discovery" json:"discovery,omitempty"`
+}
+
+// TaskStatus is a status update of a task.
+type TaskStatus struct {
+	TaskID          ID              `protobuf:"bytes,1,opt,name=task_id" json:"task_id"`
+	State           TaskState       `protobuf:"varint,2,opt,name=state" json:"state"`
+	Timestamp       float64         `protobuf:"fixed64,6,opt,name=timestamp" json:"timestamp"`
+	Healthy         *bool           `protobuf:"varint,8,opt,name=healthy" json:"healthy,omitempty"`
+	Labels          Labels          `protobuf:"bytes,12,opt,name=labels" json:"labels"`
+	ContainerStatus ContainerStatus `protobuf:"bytes,13,opt,name=container_status" json:"container_status"`
+}
+
+// ContainerStatus describes the container of a task.
+type ContainerStatus struct {
+	NetworkInfos []NetworkInfo `protobuf:"bytes,1,rep,name=network_infos" json:"network_infos,omitempty"`
+}
+
+// NetworkInfo describes a network interface of a container.
+type NetworkInfo struct {
+	IPAddresses []IPAddress `protobuf:"bytes,5,rep,name=ip_addresses" json:"ip_addresses,omitempty"`
+	Name        string      `protobuf:"bytes,6,opt,name=name" json:"name,omitempty"`
+}
+
+// IPAddress is an address of a network interface.
+type IPAddress struct {
+	IPAddress string `protobuf:"bytes,2,opt,name=ip_address" json:"ip_address,omitempty"`
+}
+
+// Labels holds key-value pairs.
+type Labels struct {
+	Labels []Label `protobuf:"bytes,1,rep,name=labels" json:"labels,omitempty"`
+}
+
+// Label is a key-value pair.
+type Label struct {
+	Key   string `protobuf:"bytes,1,opt,name=key" json:"key"`
+	Value string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+// DiscoveryInfo holds the service discovery information of a task.
+type DiscoveryInfo struct {
+	Visibility  Visibility `protobuf:"varint,1,opt,name=visibility" json:"visibility"`
+	Name        string     `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Environment string     `protobuf:"bytes,3,opt,name=environment" json:"environment,omitempty"`
+	Location    string     `protobuf:"bytes,4,opt,name=location" json:"location,omitempty"`
+	Version     string     `protobuf:"bytes,5,opt,name=version" json:"version,omitempty"`
+	Ports       Ports      `protobuf:"bytes,6,opt,name=ports" json:"ports"`
+	Labels      Labels     `protobuf:"bytes,7,opt,name=labels" json:"labels"`
+}
+
+// Ports holds the ports of a task.
+type Ports struct {
+	Ports []Port `protobuf:"bytes,1,rep,name=ports" json:"ports,omitempty"`
+}
+
+// Port is a named port of a task.
+type Port struct {
+	Number   uint32 `protobuf:"varint,1,opt,name=number" json:"number"`
+	Name     string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Protocol string `protobuf:"bytes,3,opt,name=protocol" json:"protocol,omitempty"`
+}
+
+// TaskState is the state of a task.
+type TaskState int32
+
+var taskStateNames = map[TaskState]string{
+	0: "TASK_STARTING", 1: "TASK_RUNNING", 2: "TASK_FINISHED", 3: "TASK_FAILED",
+	4: "TASK_KILLED", 5: "TASK_LOST", 6: "TASK_STAGING", 7: "TASK_ERROR",
+	8: "TASK_KILLING", 9: "TASK_DROPPED", 10: "TASK_UNREACHABLE", 11: "TASK_GONE",
+	12: "TASK_GONE_BY_OPERATOR", 13: "TASK_UNKNOWN",
+}
+
+// taskUnknown is the TASK_UNKNOWN state, which task states unknown to
+// mesos-dns decode to.
+const taskUnknown TaskState = 13
+
+// String returns the name of the task state, e.g. "TASK_RUNNING".
+func (s TaskState) String() string {
+	if name, ok := taskStateNames[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for TaskStates.
+func (s *TaskState) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalEnum(data, int32(taskUnknown), func(name string) (int32, bool) {
+		for state, n := range taskStateNames {
+			if n == name {
+				return int32(state), true
+			}
+		}
+		return 0, false
+	})
+	*s = TaskState(v)
+	return err
+}
+
+// Visibility is the visibility of a task's DiscoveryInfo.
+type Visibility int32
+
+var visibilityNames = map[Visibility]string{0: "FRAMEWORK", 1: "CLUSTER", 2: "EXTERNAL"}
+
+// String returns the name of the visibility, e.g. "CLUSTER".
+func (v Visibility) String() string {
+	if name, ok := visibilityNames[v]; ok {
+		return name
+	}
+	return strconv.Itoa(int(v))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for Visibilities.
+func (v *Visibility) UnmarshalJSON(data []byte) error {
+	n, err := unmarshalEnum(data, 0, func(name string) (int32, bool) {
+		for vis, n := range visibilityNames {
+			if n == name {
+				return int32(vis), true
+			}
+		}
+		return 0, false
+	})
+	*v = Visibility(n)
+	return err
+}
+
+// unmarshalEnum decodes an enum value given either by name or by number.
+// Unknown names, e.g. of values added by later Mesos versions, decode to the
+// given unknown value, which callers skip.
+func unmarshalEnum(data []byte, unknown int32, value func(string) (int32, bool)) (int32, error) {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var n int32
+		if err := json.Unmarshal(data, &n); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	n, ok := value(name)
+	if !ok {
+		return unknown, nil
+	}
+	return n, nil
+}
+
+// Leader returns the PID of the master, e.g. "master@10.0.0.1:5050".
+func (m MasterInfo) Leader() string {
+	if m.PID != "" {
+		return m.PID
+	}
+	ip := net.IPv4(byte(m.IP), byte(m.IP>>8), byte(m.IP>>16), byte(m.IP>>24)).String()
+	if m.Address != nil && m.Address.IP != "" {
+		ip = m.Address.IP
+	}
+	return "master@" + net.JoinHostPort(ip, strconv.FormatUint(uint64(m.Port), 10))
+}
+
+// State returns the given cluster state in the model of the /state.json Mesos
+// HTTP endpoint, led by the master with the given PID. Frameworks have no PID,
+// which the v1 operator API doesn't tell, so their schedulers' ports are
+// unknown; their hostnames fall back to the hosts of their web UIs.
+func (r GetStateResponse) State(leader string) (state.State, error) {
+	sj := state.State{Leader: leader}
+
+	for _, a := range r.GetAgents.Agents {
+		slave, err := a.slave()
+		if err != nil {
+			return state.State{}, err
+		}
+		sj.Slaves = append(sj.Slaves, slave)
+	}
+
+	index := make(map[string]int, len(r.GetFrameworks.Frameworks))
+	for _, f := range r.GetFrameworks.Frameworks {
+		fi := f.FrameworkInfo
+		index[fi.ID.Value] = len(sj.Frameworks)
+		sj.Frameworks = append(sj.Frameworks, state.Framework{
+			ID:       fi.ID.Value,
+			Name:     fi.Name,
+			Hostname: fi.host(),
+			Role:     fi.Role,
+			Roles:    fi.Roles,
+		})
+	}
+
+	for _, t := range r.GetTasks.Tasks {
+		i, ok := index[t.FrameworkID.Value]
+		if !ok {
+			continue
+		}
+		sj.Frameworks[i].Tasks = append(sj.Frameworks[i].Tasks, t.task())
+	}
+	return sj, nil
+}
+
+// slave returns the agent in the model of the /state.json endpoint. Agents
+// without a PID are given one made of their hostname and port.
+func (a Agent) slave() (state.Slave, error) {
+	pid := a.PID
+	if pid == "" {
+		port := a.AgentInfo.Port
+		if port == 0 {
+			port = 5051
+		}
+		pid = "slave(1)@" + net.JoinHostPort(a.AgentInfo.Hostname, strconv.Itoa(int(port)))
+	}
+	u, err := upid.Parse(pid)
+	if err != nil {
+		return state.Slave{}, fmt.Errorf("agent %s: %v", a.AgentInfo.ID.Value, err)
+	}
+
+	attrs := make(state.Attributes, len(a.AgentInfo.Attributes))
+	for _, attr := range a.AgentInfo.Attributes {
+		switch {
+		case attr.Text != nil:
+			attrs[attr.Name] = attr.Text.Value
+		case attr.Scalar != nil:
+			attrs[attr.Name] = strconv.FormatFloat(attr.Scalar.Value, 'f', -1, 64)
+		}
+	}
+	return state.Slave{
+		ID:         a.AgentInfo.ID.Value,
+		Hostname:   a.AgentInfo.Hostname,
+		PID:        state.PID{UPID: u},
+		Attributes: attrs,
+	}, nil
+}
+
+// task returns the task in the model of the /state.json endpoint.
+func (t Task) task() state.Task {
+	task := state.Task{
+		FrameworkID: t.FrameworkID.Value,
+		ID:          t.TaskID.Value,
+		Name:        t.Name,
+		SlaveID:     t.AgentID.Value,
+		State:       t.State.String(),
+		Labels:      t.Labels.labels(),
+	}
+
+	var ranges []string
+	for _, r := range t.Resources {
+		switch {
+		case r.Name == "ports" && r.Ranges != nil:
+			for _, rg := range r.Ranges.Range {
+				ranges = append(ranges, fmt.Sprintf("%d-%d", rg.Begin, rg.End))
+			}
+		case r.Scalar != nil:
+			switch r.Name {
+			case "cpus":
+				task.CPUs += r.Scalar.Value
+			case "mem":
+				task.Mem += r.Scalar.Value
+			case "disk":
+				task.Disk += r.Scalar.Value
+			}
+		}
+	}
+	if len(ranges) > 0 {
+		task.PortRanges = "[" + strings.Join(ranges, ", ") + "]"
+	}
+
+	for _, s := range t.Statuses {
+		status := state.Status{
+			Timestamp: s.Timestamp,
+			State:     s.State.String(),
+			Labels:    s.Labels.labels(),
+			Healthy:   s.Healthy,
+		}
+		for _, n := range s.ContainerStatus.NetworkInfos {
+			ni := state.NetworkInfo{Name: n.Name}
+			for _, a := range n.IPAddresses {
+				ni.IPAddresses = append(ni.IPAddresses, state.IPAddress{IPAddress: a.IPAddress})
+			}
+			status.ContainerStatus.NetworkInfos = append(status.ContainerStatus.NetworkInfos, ni)
+		}
+		task.Statuses = append(task.Statuses, status)
+	}
+
+	if d := t.Discovery; d != nil {
+		task.DiscoveryInfo.Visibilty = d.Visibility.String()
+		task.DiscoveryInfo.Name = d.Name
+		task.DiscoveryInfo.Environment = d.Environment
+		task.DiscoveryInfo.Location = d.Location
+		task.DiscoveryInfo.Version = d.Version
+		task.DiscoveryInfo.Labels.Labels = d.Labels.labels()
+		for _, p := range d.Ports.Ports {
+			task.DiscoveryInfo.Ports.DiscoveryPorts = append(task.DiscoveryInfo.Ports.DiscoveryPorts,
+				state.DiscoveryPort{Protocol: p.Protocol, Number: int(p.Number), Name: p.Name})
+		}
+	}
+	return task
+}
+
+// labels returns the labels in the model of the /state.json endpoint.
+func (ls Labels) labels() []state.Label {
+	if len(ls.Labels) == 0 {
+		return nil
+	}
+	labels := make([]state.Label, 0, len(ls.Labels))
+	for _, l := range ls.Labels {
+		labels = append(labels, state.Label{Key: l.Key, Value: l.Value})
+	}
+	return labels
+}
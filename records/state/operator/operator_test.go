@@ -0,0 +1,206 @@
+package operator
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/mesosphere/mesos-dns/records/state"
+)
+
+const getStateJSON = `{
+	"type": "GET_STATE",
+	"get_state": {
+		"get_tasks": {"tasks": [{
+			"name": "web",
+			"task_id": {"value": "web.1"},
+			"framework_id": {"value": "fw0"},
+			"agent_id": {"value": "s0"},
+			"state": "TASK_RUNNING",
+			"resources": [
+				{"name": "cpus", "type": "SCALAR", "scalar": {"value": 0.5}},
+				{"name": "ports", "type": "RANGES", "ranges": {"range": [{"begin": 31000, "end": 31001}, {"begin": 31005, "end": 31005}]}}
+			],
+			"statuses": [{
+				"task_id": {"value": "web.1"},
+				"state": "TASK_RUNNING",
+				"timestamp": 1.5,
+				"healthy": true,
+				"labels": {"labels": [{"key": "k", "value": "v"}]},
+				"container_status": {"network_infos": [{"name": "front", "ip_addresses": [{"protocol": "IPv4", "ip_address": "10.0.0.1"}]}]}
+			}],
+			"labels": {"labels": [{"key": "DNS_TTL", "value": "5"}]},
+			"discovery": {
+				"visibility": "EXTERNAL",
+				"name": "www",
+				"version": "2",
+				"ports": {"ports": [{"number": 80, "name": "http", "protocol": "tcp"}]}
+			}
+		}, {
+			"name": "orphan",
+			"task_id": {"value": "orphan.1"},
+			"framework_id": {"value": "unknown"},
+			"agent_id": {"value": "s0"},
+			"state": "TASK_RUNNING"
+		}]},
+		"get_frameworks": {"frameworks": [{
+			"framework_info": {"user": "root", "name": "marathon", "id": {"value": "fw0"}, "role": "prod", "hostname": "marathon.example.com"},
+			"active": true,
+			"connected": true
+		}]},
+		"get_agents": {"agents": [{
+			"agent_info": {
+				"hostname": "agent1.example.com",
+				"port": 5051,
+				"id": {"value": "s0"},
+				"attributes": [
+					{"name": "rack", "type": "TEXT", "text": {"value": "r1"}},
+					{"name": "level", "type": "SCALAR", "scalar": {"value": 2}}
+				]
+			},
+			"active": true,
+			"version": "1.4.0",
+			"pid": "slave(1)@10.0.1.1:5051"
+		}, {
+			"agent_info": {"hostname": "10.0.1.2", "id": {"value": "s1"}},
+			"active": true,
+			"version": "1.4.0"
+		}]}
+	}
+}`
+
+func TestGetStateResponse_State(t *testing.T) {
+	var r Response
+	if err := json.Unmarshal([]byte(getStateJSON), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.GetState == nil {
+		t.Fatal("no GET_STATE response")
+	}
+	sj, err := r.GetState.State("master@10.0.0.9:5050")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	healthy := true
+	task := state.Task{
+		FrameworkID: "fw0",
+		ID:          "web.1",
+		Name:        "web",
+		SlaveID:     "s0",
+		State:       "TASK_RUNNING",
+		Statuses: []state.Status{{
+			Timestamp: 1.5,
+			State:     "TASK_RUNNING",
+			Labels:    []state.Label{{Key: "k", Value: "v"}},
+			Healthy:   &healthy,
+			ContainerStatus: state.ContainerStatus{NetworkInfos: []state.NetworkInfo{{
+				Name:        "front",
+				IPAddresses: []state.IPAddress{{IPAddress: "10.0.0.1"}},
+			}}},
+		}},
+		Labels: []state.Label{{Key: "DNS_TTL", Value: "5"}},
+	}
+	task.CPUs = 0.5
+	task.PortRanges = "[31000-31001, 31005-31005]"
+	task.DiscoveryInfo.Visibilty = "EXTERNAL"
+	task.DiscoveryInfo.Name = "www"
+	task.DiscoveryInfo.Version = "2"
+	task.DiscoveryInfo.Ports.DiscoveryPorts = []state.DiscoveryPort{{Protocol: "tcp", Number: 80, Name: "http"}}
+
+	if got, want := sj.Leader, "master@10.0.0.9:5050"; got != want {
+		t.Errorf("got leader %q, want %q", got, want)
+	}
+	if len(sj.Frameworks) != 1 {
+		t.Fatalf("got %d frameworks, want 1", len(sj.Frameworks))
+	}
+	f := sj.Frameworks[0]
+	if f.ID != "fw0" || f.Name != "marathon" || f.Role != "prod" || f.Hostname != "marathon.example.com" {
+		t.Errorf("got framework %+v", f)
+	}
+	if !reflect.DeepEqual(f.Tasks, []state.Task{task}) {
+		t.Errorf("got tasks\n%+v\nwant\n%+v", f.Tasks, []state.Task{task})
+	}
+	if got := f.Tasks[0].Ports(); !reflect.DeepEqual(got, []string{"31000", "31001", "31005"}) {
+		t.Errorf("got ports %q", got)
+	}
+
+	if len(sj.Slaves) != 2 {
+		t.Fatalf("got %d slaves, want 2", len(sj.Slaves))
+	}
+	for i, tt := range []struct {
+		id, hostname, host, port string
+		attrs                    state.Attributes
+	}{
+		{"s0", "agent1.example.com", "10.0.1.1", "5051", state.Attributes{"rack": "r1", "level": "2"}},
+		{"s1", "10.0.1.2", "10.0.1.2", "5051", state.Attributes{}},
+	} {
+		s := sj.Slaves[i]
+		if s.ID != tt.id || s.Hostname != tt.hostname || s.PID.Host != tt.host || s.PID.Port != tt.port {
+			t.Errorf("slave #%d: got %+v (pid %v)", i, s, s.PID.UPID)
+		}
+		if !reflect.DeepEqual(s.Attributes, tt.attrs) {
+			t.Errorf("slave #%d: got attributes %v, want %v", i, s.Attributes, tt.attrs)
+		}
+	}
+}
+
+func TestFrameworkInfo_host(t *testing.T) {
+	for i, tt := range []struct {
+		fi   FrameworkInfo
+		want string
+	}{
+		{FrameworkInfo{Hostname: "marathon.example.com", WebUIURL: "http://10.0.0.1:8080"}, "marathon.example.com"},
+		{FrameworkInfo{WebUIURL: "http://10.0.0.1:8080"}, "10.0.0.1"},
+		{FrameworkInfo{WebUIURL: "https://chronos.example.com/ui"}, "chronos.example.com"},
+		{FrameworkInfo{WebUIURL: "http://[fd00::1]:8080"}, "fd00::1"},
+		{FrameworkInfo{WebUIURL: "%"}, ""},
+		{FrameworkInfo{}, ""},
+	} {
+		if got := tt.fi.host(); got != tt.want {
+			t.Errorf("test #%d: got host %q, want %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestResponse_Protobuf(t *testing.T) {
+	var want Response
+	if err := json.Unmarshal([]byte(getStateJSON), &want); err != nil {
+		t.Fatal(err)
+	}
+	want.GetMaster = &GetMasterResponse{MasterInfo: MasterInfo{ID: "m", IP: 0x0900000a, Port: 5050}}
+
+	data, err := proto.Marshal(&want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Response
+	if err := proto.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+
+	// a GET_STATE call is a varint type field
+	if data, err := proto.Marshal(&Call{Type: GetState}); err != nil || !bytes.Equal(data, []byte{0x08, 0x09}) {
+		t.Errorf("got encoded call %x (err %v)", data, err)
+	}
+}
+
+func TestMasterInfo_Leader(t *testing.T) {
+	for i, tt := range []struct {
+		info MasterInfo
+		want string
+	}{
+		{MasterInfo{PID: "master@10.0.0.9:5050", IP: 1, Port: 1}, "master@10.0.0.9:5050"},
+		{MasterInfo{IP: 0x0900000a, Port: 5050}, "master@10.0.0.9:5050"},
+		{MasterInfo{IP: 1, Port: 5050, Address: &Address{IP: "10.0.0.8", Port: 5050}}, "master@10.0.0.8:5050"},
+	} {
+		if got := tt.info.Leader(); got != tt.want {
+			t.Errorf("test #%d: got %q, want %q", i, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package state
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"net"
 	"strconv"
 	"strings"
@@ -11,7 +13,25 @@ import (
 
 // Resources holds resources as defined in the /state.json Mesos HTTP endpoint.
 type Resources struct {
-	PortRanges string `json:"ports"`
+	PortRanges string  `json:"ports"`
+	CPUs       float64 `json:"cpus"`
+	Mem        float64 `json:"mem"`
+	Disk       float64 `json:"disk"`
+}
+
+// Scalar returns the value of the scalar resource with the given name
+// ("cpus", "mem" or "disk"), or zero if unknown.
+func (r Resources) Scalar(name string) float64 {
+	switch name {
+	case "cpus":
+		return r.CPUs
+	case "mem":
+		return r.Mem
+	case "disk":
+		return r.Disk
+	default:
+		return 0
+	}
 }
 
 // Ports returns a slice of individual ports expanded from PortRanges.
@@ -47,9 +67,11 @@ type Label struct {
 
 // Status holds a task status as defined in the /state.json Mesos HTTP endpoint.
 type Status struct {
-	Timestamp       float64         `json:"timestamp"`
-	State           string          `json:"state"`
-	Labels          []Label         `json:"labels,omitempty"`
+	Timestamp float64 `json:"timestamp"`
+	State     string  `json:"state"`
+	Labels    []Label `json:"labels,omitempty"`
+	// Healthy is the result of the task's health checks, if it has any.
+	Healthy         *bool           `json:"healthy,omitempty"`
 	ContainerStatus ContainerStatus `json:"container_status,omitempty"`
 }
 
@@ -62,11 +84,30 @@ type ContainerStatus struct {
 // NetworkInfo holds the network configuration for a single interface
 // as defined in the /state.json Mesos HTTP endpoint.
 type NetworkInfo struct {
+	// Name is the name of the network, e.g. of a CNI network.
+	Name        string      `json:"name,omitempty"`
 	IPAddresses []IPAddress `json:"ip_addresses,omitempty"`
 	// back-compat with 0.25 IPAddress format
 	IPAddress string `json:"ip_address,omitempty"`
 }
 
+// addresses returns the IP addresses of the interface.
+func (n NetworkInfo) addresses() []string {
+	if len(n.IPAddresses) == 0 {
+		// Fall back to v0.25 syntax of single IPAddress if that's being used.
+		if n.IPAddress != "" {
+			return []string{n.IPAddress}
+		}
+		return nil
+	}
+	// In v0.26, we use the IPAddresses field.
+	addrs := make([]string, 0, len(n.IPAddresses))
+	for _, ipAddress := range n.IPAddresses {
+		addrs = append(addrs, ipAddress.IPAddress)
+	}
+	return addrs
+}
+
 // IPAddress holds a single IP address configured on an interface,
 // as defined in the /state.json Mesos HTTP endpoint.
 type IPAddress struct {
@@ -81,6 +122,7 @@ type Task struct {
 	SlaveID       string   `json:"slave_id"`
 	State         string   `json:"state"`
 	Statuses      []Status `json:"statuses"`
+	Labels        []Label  `json:"labels,omitempty"`
 	Resources     `json:"resources"`
 	DiscoveryInfo DiscoveryInfo `json:"discovery"`
 
@@ -92,6 +134,32 @@ func (t *Task) HasDiscoveryInfo() bool {
 	return t.DiscoveryInfo.Name != ""
 }
 
+// LabelValue returns the value of the label with the given key, looked up in
+// the Task's DiscoveryInfo, the Task itself and its latest running status, in
+// that order.
+func (t *Task) LabelValue(key string) (string, bool) {
+	for _, ls := range [][]Label{t.DiscoveryInfo.Labels.Labels, t.Labels} {
+		for _, l := range ls {
+			if l.Key == key {
+				return l.Value, true
+			}
+		}
+	}
+	if vs := statusIPs(t.Statuses, labels(key)); len(vs) > 0 {
+		return vs[0], true
+	}
+	return "", false
+}
+
+// Healthy returns whether the task is healthy according to its latest running
+// status, and whether that status reports health check results at all.
+func (t *Task) Healthy() (healthy, known bool) {
+	if s := latestRunning(t.Statuses); s != nil && s.Healthy != nil {
+		return *s.Healthy, true
+	}
+	return false, false
+}
+
 // IP returns the first Task IP found in the given sources.
 func (t *Task) IP(srcs ...string) string {
 	if ips := t.IPs(srcs...); len(ips) > 0 {
@@ -118,6 +186,27 @@ func (t *Task) IPs(srcs ...string) (ips []net.IP) {
 	return ips
 }
 
+// NetworkIPs returns the IP addresses of the Task in each named network of its
+// latest running status, by network name.
+func (t *Task) NetworkIPs() map[string][]net.IP {
+	s := latestRunning(t.Statuses)
+	if s == nil {
+		return nil
+	}
+	ips := map[string][]net.IP{}
+	for _, netinfo := range s.ContainerStatus.NetworkInfos {
+		if netinfo.Name == "" {
+			continue
+		}
+		for _, addr := range netinfo.addresses() {
+			if ip := net.ParseIP(addr); ip != nil {
+				ips[netinfo.Name] = append(ips[netinfo.Name], ip)
+			}
+		}
+	}
+	return ips
+}
+
 // sources maps the string representation of IP sources to their functions.
 var sources = map[string]func(*Task) []string{
 	"host":    hostIPs,
@@ -134,19 +223,9 @@ func hostIPs(t *Task) []string { return []string{t.SlaveIP} }
 // []Status.ContainerStatus.[]NetworkInfos.[]IPAddresses.IPAddress
 func networkInfoIPs(t *Task) []string {
 	return statusIPs(t.Statuses, func(s *Status) []string {
-		ips := make([]string, len(s.ContainerStatus.NetworkInfos))
+		var ips []string
 		for _, netinfo := range s.ContainerStatus.NetworkInfos {
-			if len(netinfo.IPAddresses) > 0 {
-				// In v0.26, we use the IPAddresses field.
-				for _, ipAddress := range netinfo.IPAddresses {
-					ips = append(ips, ipAddress.IPAddress)
-				}
-			} else {
-				// Fall back to v0.25 syntax of single IPAddress if that's being used.
-				if netinfo.IPAddress != "" {
-					ips = append(ips, netinfo.IPAddress)
-				}
-			}
+			ips = append(ips, netinfo.addresses()...)
 		}
 		return ips
 	})
@@ -174,6 +253,14 @@ func mesosIPs(t *Task) []string {
 
 // statusIPs returns the latest running status IPs extracted with the given src
 func statusIPs(st []Status, src func(*Status) []string) []string {
+	if s := latestRunning(st); s != nil {
+		return src(s)
+	}
+	return nil
+}
+
+// latestRunning returns the latest running status, if any.
+func latestRunning(st []Status) *Status {
 	// the state.json we extract from mesos makes no guarantees re: the order
 	// of the task statuses so we should check the timestamps to avoid problems
 	// down the line. we can't rely on seeing the same sequence. (@joris)
@@ -185,7 +272,7 @@ func statusIPs(st []Status, src func(*Status) []string) []string {
 		}
 	}
 	if j >= 0 {
-		return src(&st[j])
+		return &st[j]
 	}
 	return nil
 }
@@ -206,10 +293,25 @@ func labels(key string) func(*Status) []string {
 
 // Framework holds a framework as defined in the /state.json Mesos HTTP endpoint.
 type Framework struct {
-	Tasks    []Task `json:"tasks"`
-	PID      PID    `json:"pid"`
-	Name     string `json:"name"`
-	Hostname string `json:"hostname"`
+	ID       string   `json:"id"`
+	Tasks    []Task   `json:"tasks"`
+	PID      PID      `json:"pid"`
+	Name     string   `json:"name"`
+	Hostname string   `json:"hostname"`
+	Role     string   `json:"role"`
+	Roles    []string `json:"roles,omitempty"`
+}
+
+// AllRoles returns the roles of a framework: its Roles if it's a multi-role
+// framework, or else its Role, if any.
+func (f Framework) AllRoles() []string {
+	if len(f.Roles) > 0 {
+		return f.Roles
+	}
+	if f.Role != "" {
+		return []string{f.Role}
+	}
+	return nil
 }
 
 // HostPort returns the hostname and port where a framework's scheduler is
@@ -223,9 +325,34 @@ func (f Framework) HostPort() (string, string) {
 
 // Slave holds a slave as defined in the /state.json Mesos HTTP endpoint.
 type Slave struct {
-	ID       string `json:"id"`
-	Hostname string `json:"hostname"`
-	PID      PID    `json:"pid"`
+	ID         string     `json:"id"`
+	Hostname   string     `json:"hostname"`
+	PID        PID        `json:"pid"`
+	Attributes Attributes `json:"attributes"`
+}
+
+// Attributes holds the attributes of a slave as defined in the /state.json
+// Mesos HTTP endpoint, by name. Scalar values are formatted as strings.
+type Attributes map[string]string
+
+// UnmarshalJSON implements the json.Unmarshaler interface for Attributes.
+func (a *Attributes) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*a = make(Attributes, len(raw))
+	for name, v := range raw {
+		switch v := v.(type) {
+		case string:
+			(*a)[name] = v
+		case float64:
+			(*a)[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			(*a)[name] = fmt.Sprint(v)
+		}
+	}
+	return nil
 }
 
 // PID holds a Mesos PID and implements the json.Unmarshaler interface.
@@ -18,6 +18,26 @@ func TestResources_Ports(t *testing.T) {
 	}
 }
 
+func TestResources_Scalar(t *testing.T) {
+	var r Resources
+	if err := json.Unmarshal([]byte(`{"cpus": 0.5, "mem": 128, "disk": 0, "ports": "[]"}`), &r); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		want float64
+	}{
+		{"cpus", 0.5},
+		{"mem", 128},
+		{"disk", 0},
+		{"gpus", 0},
+	} {
+		if got := r.Scalar(tt.name); got != tt.want {
+			t.Errorf("Scalar(%q): got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestPID_UnmarshalJSON(t *testing.T) {
 	makePID := func(id, host, port string) PID {
 		return PID{UPID: &upid.UPID{ID: id, Host: host, Port: port}}
@@ -41,6 +61,32 @@ func TestPID_UnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestAttributes_UnmarshalJSON(t *testing.T) {
+	var a Attributes
+	if err := json.Unmarshal([]byte(`{"rack": "12", "zone": "b", "cores": 8, "load": 0.5}`), &a); err != nil {
+		t.Fatal(err)
+	}
+	want := Attributes{"rack": "12", "zone": "b", "cores": "8", "load": "0.5"}
+	if !reflect.DeepEqual(a, want) {
+		t.Errorf("got %v, want %v", a, want)
+	}
+}
+
+func TestFramework_AllRoles(t *testing.T) {
+	for i, tt := range []struct {
+		Framework
+		want []string
+	}{
+		{Framework{}, nil},
+		{Framework{Role: "prod"}, []string{"prod"}},
+		{Framework{Role: "prod", Roles: []string{"web", "db"}}, []string{"web", "db"}},
+	} {
+		if got := tt.AllRoles(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test #%d: got %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
 func TestTask_IPs(t *testing.T) {
 	for i, tt := range []struct {
 		*Task
@@ -136,6 +182,76 @@ func TestTask_IPs(t *testing.T) {
 	}
 }
 
+func TestTask_NetworkIPs(t *testing.T) {
+	tk := task(statuses(
+		status(state("TASK_RUNNING"), timestamp(1), netinfos(named("old", netinfo("1.1.1.1")))),
+		status(state("TASK_RUNNING"), timestamp(2), netinfos(
+			named("front", netinfo("10.0.0.1", "fd00::1")),
+			named("back", oldnetinfo("10.1.0.1")),
+			netinfo("10.2.0.1"),
+		)),
+	))
+	want := map[string][]net.IP{
+		"front": ips("10.0.0.1", "fd00::1"),
+		"back":  ips("10.1.0.1"),
+	}
+	if got := tk.NetworkIPs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := tk.IPs("netinfo"), ips("10.0.0.1", "fd00::1", "10.1.0.1", "10.2.0.1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("IPs: got %v, want %v", got, want)
+	}
+}
+
+func TestTask_LabelValue(t *testing.T) {
+	tk := task(
+		taskLabels("version", "1", "proto", "http"),
+		discoveryLabels("version", "2"),
+		statuses(
+			status(state("TASK_RUNNING"), labels("zone", "a", "proto", "grpc"), timestamp(2)),
+			status(state("TASK_RUNNING"), labels("zone", "b"), timestamp(1)),
+		),
+	)
+	for i, tt := range []struct {
+		key, want string
+		ok        bool
+	}{
+		{"version", "2", true},
+		{"proto", "http", true},
+		{"zone", "a", true},
+		{"missing", "", false},
+	} {
+		if got, ok := tk.LabelValue(tt.key); got != tt.want || ok != tt.ok {
+			t.Errorf("test #%d: LabelValue(%q): got (%q, %v), want (%q, %v)", i, tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTask_Healthy(t *testing.T) {
+	for i, tt := range []struct {
+		*Task
+		healthy, known bool
+	}{
+		{task(), false, false},
+		{task(statuses(status(state("TASK_RUNNING")))), false, false},
+		{task(statuses(status(state("TASK_RUNNING"), healthy(true)))), true, true},
+		{task(statuses(status(state("TASK_RUNNING"), healthy(false)))), false, true},
+		{ // the latest running status wins
+			Task: task(statuses(
+				status(state("TASK_RUNNING"), healthy(true), timestamp(1)),
+				status(state("TASK_RUNNING"), healthy(false), timestamp(2)),
+				status(state("TASK_KILLED"), healthy(true), timestamp(3)),
+			)),
+			healthy: false,
+			known:   true,
+		},
+	} {
+		if healthy, known := tt.Healthy(); healthy != tt.healthy || known != tt.known {
+			t.Errorf("test #%d: got (%v, %v), want (%v, %v)", i, healthy, known, tt.healthy, tt.known)
+		}
+	}
+}
+
 // test helpers
 
 type (
@@ -165,6 +281,16 @@ func statuses(st ...Status) taskOpt {
 	}
 }
 
+func taskLabels(kvs ...string) taskOpt {
+	return func(t *Task) { t.Labels = append(t.Labels, kvLabels(kvs...)...) }
+}
+
+func discoveryLabels(kvs ...string) taskOpt {
+	return func(t *Task) {
+		t.DiscoveryInfo.Labels.Labels = append(t.DiscoveryInfo.Labels.Labels, kvLabels(kvs...)...)
+	}
+}
+
 func slaveIP(ip string) taskOpt {
 	return func(t *Task) { t.SlaveIP = ip }
 }
@@ -178,14 +304,18 @@ func status(opts ...statusOpt) Status {
 }
 
 func labels(kvs ...string) statusOpt {
+	return func(s *Status) { s.Labels = append(s.Labels, kvLabels(kvs...)...) }
+}
+
+func kvLabels(kvs ...string) []Label {
 	if len(kvs)%2 != 0 {
 		panic("odd number")
 	}
-	return func(s *Status) {
-		for i := 0; i < len(kvs); i += 2 {
-			s.Labels = append(s.Labels, Label{Key: kvs[i], Value: kvs[i+1]})
-		}
+	ls := make([]Label, 0, len(kvs)/2)
+	for i := 0; i < len(kvs); i += 2 {
+		ls = append(ls, Label{Key: kvs[i], Value: kvs[i+1]})
 	}
+	return ls
 }
 
 func state(st string) statusOpt {
@@ -206,6 +336,11 @@ func netinfo(ips ...string) NetworkInfo {
 	return netinfo
 }
 
+func named(name string, netinfo NetworkInfo) NetworkInfo {
+	netinfo.Name = name
+	return netinfo
+}
+
 // NetworkInfo using v0.25 syntax for storing a single IP.
 func oldnetinfo(ip string) NetworkInfo {
 	netinfo := NetworkInfo{}
@@ -221,6 +356,10 @@ func oldnewnetinfo(oldip string, newip string) NetworkInfo {
 	return netinfo
 }
 
+func healthy(h bool) statusOpt {
+	return func(s *Status) { s.Healthy = &h }
+}
+
 func timestamp(t float64) statusOpt {
 	return func(s *Status) { s.Timestamp = t }
 }
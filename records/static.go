@@ -0,0 +1,181 @@
+package records
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/miekg/dns"
+)
+
+// StaticClass is the record class of the records read from StaticFiles. It
+// isn't subject to per-class configuration such as IP filters.
+const StaticClass = "static"
+
+// StaticRecord is an entry of a JSON record file: a record with the given
+// name, type and data in zone file syntax, e.g.
+//
+//	{"name": "db.mesos.", "type": "SRV", "ttl": 60, "data": "0 0 5432 db1.mesos."}
+//
+// Relative names are relative to the domain. A zero TTL selects the
+// configured one.
+type StaticRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	TTL  uint32 `json:"ttl,omitempty"`
+	Data string `json:"data"`
+}
+
+// StaticFiles reads the records of RFC 1035 zone files and JSON record files
+// (those with a .json extension), keeping them across record generations and
+// only re-reading the files which changed. It's safe for concurrent use.
+type StaticFiles struct {
+	mu    sync.Mutex
+	files map[string]staticFile
+}
+
+// staticFile holds the records read from a file along with what identifies
+// the version of the file they were read from.
+type staticFile struct {
+	origin  string
+	modTime time.Time
+	size    int64
+	rrs     []RR
+}
+
+// NewStaticFiles returns an empty StaticFiles.
+func NewStaticFiles() *StaticFiles {
+	return &StaticFiles{files: map[string]staticFile{}}
+}
+
+// Records returns the records of the given files, with relative names
+// relative to the given origin (e.g. "mesos."), re-reading the files which
+// changed since they were last read. Files which can't be read keep their
+// previous records, if any.
+func (s *StaticFiles) Records(paths []string, origin string) []RR {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rrs []RR
+	for _, path := range paths {
+		f, ok := s.files[path]
+		fi, err := os.Stat(path)
+		if err != nil {
+			logging.Error.Printf("cannot read static records: %v", err)
+			rrs = append(rrs, f.rrs...)
+			continue
+		}
+		if !ok || f.origin != origin || !f.modTime.Equal(fi.ModTime()) || f.size != fi.Size() {
+			frrs, err := readStaticFile(path, origin)
+			if err != nil {
+				logging.Error.Printf("cannot read static records of %s: %v", path, err)
+				rrs = append(rrs, f.rrs...)
+				continue
+			}
+			logging.Verbose.Printf("read %d static records from %s", len(frrs), path)
+			f = staticFile{origin: origin, modTime: fi.ModTime(), size: fi.Size(), rrs: frrs}
+			s.files[path] = f
+		}
+		rrs = append(rrs, f.rrs...)
+	}
+	return rrs
+}
+
+// readStaticFile reads the records of the given zone or JSON record file.
+func readStaticFile(path, origin string) ([]RR, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			logging.Error.Println(err)
+		}
+	}()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseRecordFile(f, origin)
+	}
+	return parseZoneFile(f, origin, path)
+}
+
+// parseZoneFile parses the records of the given RFC 1035 zone file.
+func parseZoneFile(r io.Reader, origin, path string) ([]RR, error) {
+	var rrs []RR
+	var err error
+	for t := range dns.ParseZone(r, origin, path) {
+		if err != nil {
+			continue // drain the parser
+		}
+		if t.Error != nil {
+			err = t.Error
+			continue
+		}
+		var rr RR
+		if rr, err = staticRR(t.RR); err == nil {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs, err
+}
+
+// parseRecordFile parses the records of the given JSON record file, an array
+// of StaticRecords.
+func parseRecordFile(r io.Reader, origin string) ([]RR, error) {
+	var srs []StaticRecord
+	if err := json.NewDecoder(r).Decode(&srs); err != nil {
+		return nil, err
+	}
+	rrs := make([]RR, 0, len(srs))
+	for i, sr := range srs {
+		name := sr.Name
+		if !dns.IsFqdn(name) {
+			name = dns.Fqdn(name + "." + origin)
+		}
+		drr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", name, sr.TTL, sr.Type, sr.Data))
+		if err != nil {
+			return nil, fmt.Errorf("record #%d: %v", i, err)
+		}
+		if drr == nil {
+			return nil, fmt.Errorf("record #%d: no record", i)
+		}
+		rr, err := staticRR(drr)
+		if err != nil {
+			return nil, fmt.Errorf("record #%d: %v", i, err)
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+// staticRR converts the given parsed record of a static file. Only A, AAAA,
+// CNAME, SRV, TXT, MX and NS records are supported.
+func staticRR(drr dns.RR) (RR, error) {
+	hdr := drr.Header()
+	rr := RR{Name: strings.ToLower(hdr.Name), Type: hdr.Rrtype, TTL: hdr.Ttl, Class: StaticClass}
+	switch v := drr.(type) {
+	case *dns.A:
+		rr.IP = v.A.To4()
+	case *dns.AAAA:
+		rr.IP = v.AAAA
+	case *dns.CNAME:
+		rr.Target = strings.ToLower(v.Target)
+	case *dns.SRV:
+		rr.Target, rr.Port = strings.ToLower(v.Target), v.Port
+		rr.Priority, rr.Weight = v.Priority, v.Weight
+	case *dns.TXT:
+		rr.Text = strings.Join(v.Txt, "")
+	case *dns.MX:
+		rr.Target, rr.Priority = strings.ToLower(v.Mx), v.Preference
+	case *dns.NS:
+		rr.Target = strings.ToLower(v.Ns)
+	default:
+		return RR{}, fmt.Errorf("unsupported %s record %s", dns.TypeToString[hdr.Rrtype], hdr.Name)
+	}
+	return rr, nil
+}
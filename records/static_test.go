@@ -0,0 +1,91 @@
+package records
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStaticFiles_Records(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	zone := filepath.Join(dir, "infra.zone")
+	records := filepath.Join(dir, "infra.json")
+	write := func(path, content string, mtime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(zone, `$TTL 300
+db      IN A     10.0.0.5
+db      IN AAAA  fd00::5
+www     IN CNAME db
+_pg._tcp 60 IN SRV 0 10 5432 db
+db      IN TXT   "role=primary"
+mail    IN MX    10 db
+sub     IN NS    ns.sub
+ns.sub  IN A     10.0.0.53
+`, time.Unix(1, 0))
+	write(records, `[
+		{"name": "cache", "type": "A", "data": "10.0.0.6"},
+		{"name": "Queue.mesos.", "type": "A", "ttl": 10, "data": "10.0.0.7"}
+	]`, time.Unix(1, 0))
+
+	s := NewStaticFiles()
+	got := strings.Join(rrStrings(s.Records([]string{zone, records}, "mesos.")), "\n")
+	want := strings.Join([]string{
+		"db.mesos. A 10.0.0.5 300",
+		"db.mesos. AAAA fd00::5 300",
+		"www.mesos. CNAME db.mesos. 300",
+		"_pg._tcp.mesos. SRV db.mesos.:5432 60",
+		"db.mesos. TXT role=primary 300",
+		"mail.mesos. MX 10 db.mesos. 300",
+		"sub.mesos. NS ns.sub.mesos. 300",
+		"ns.sub.mesos. A 10.0.0.53 300",
+		"cache.mesos. A 10.0.0.6 0",
+		"queue.mesos. A 10.0.0.7 10",
+	}, "\n")
+	if got != want {
+		t.Errorf("got records:\n%s\nwant:\n%s", got, want)
+	}
+
+	// changed files are re-read, broken ones keep their previous records
+	write(zone, "db IN A 10.0.0.8\n", time.Unix(2, 0))
+	write(records, `[{"name": "cache", "type": "HINFO", "data": "a b"}]`, time.Unix(2, 0))
+	if got, want := rrStrings(s.Records([]string{zone, records}, "mesos.")), []string{
+		"db.mesos. A 10.0.0.8 3600",
+		"cache.mesos. A 10.0.0.6 0",
+		"queue.mesos. A 10.0.0.7 10",
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got records %q, want %q", got, want)
+	}
+
+	if _, err := readStaticFile(filepath.Join(dir, "missing.zone"), "mesos."); err == nil {
+		t.Error("missing file: got no error")
+	}
+	if _, err := readStaticFile(records, "mesos."); err == nil {
+		t.Error("unsupported record type: got no error")
+	}
+}
+
+// rrStrings returns the textual representations of the given records along
+// with their TTLs.
+func rrStrings(rrs []RR) []string {
+	ss := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		ss = append(ss, rr.String()+" "+strconv.Itoa(int(rr.TTL)))
+	}
+	return ss
+}
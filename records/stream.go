@@ -0,0 +1,294 @@
+package records
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mesos/mesos-go/upid"
+	"github.com/mesosphere/mesos-dns/errorutil"
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/mesosphere/mesos-dns/records/state"
+	"github.com/mesosphere/mesos-dns/records/state/operator"
+)
+
+const (
+	// streamRetryDelay is how long a StateStream waits before resubscribing
+	// after its subscription failed.
+	streamRetryDelay = 5 * time.Second
+	// missedHeartbeats is the number of heartbeat intervals after which a
+	// silent subscription is deemed broken.
+	missedHeartbeats = 3
+	// heartbeatInterval is the default heartbeat interval of masters, used
+	// until the SUBSCRIBED event tells the actual one.
+	heartbeatInterval = 15 * time.Second
+)
+
+// StateStream keeps the state of the cluster up to date with the events of
+// the SUBSCRIBE call of the v1 operator API of the leading master, sending
+// the state on its States channel after each batch of events, at most once
+// per StreamIntervalSeconds since every state sent regenerates all the
+// records. It resubscribes when its subscription breaks, when the masters
+// change and when asked to Resync, each subscription starting with a full
+// snapshot of the state.
+type StateStream struct {
+	config  Config
+	client  http.Client
+	masters chan []string
+	resync  chan struct{}
+	states  chan state.State
+
+	// mu guards the states held until the interval since the last one sent
+	// elapses.
+	mu       sync.Mutex
+	interval time.Duration
+	last     time.Time
+	pending  *state.State
+	timer    *time.Timer
+}
+
+// NewStateStream returns a StateStream configured with the given Config,
+// sending its requests through the given http.RoundTripper, e.g. one returned
+// by NewMasterTransport. It starts streaming once Run and given the masters
+// with SetMasters.
+func NewStateStream(c Config, transport http.RoundTripper) *StateStream {
+	return &StateStream{
+		config: c,
+		// the client can't time out requests, which last as long as their
+		// subscription, so only the transport times out their headers.
+		client:   http.Client{Transport: transport},
+		masters:  make(chan []string, 1),
+		resync:   make(chan struct{}, 1),
+		states:   make(chan state.State, 1),
+		interval: time.Duration(c.StreamIntervalSeconds) * time.Second,
+	}
+}
+
+// States returns the channel on which the state of the cluster is sent after
+// each batch of events, or once the StreamIntervalSeconds since the last one
+// elapse. Only the latest state is kept until it's received.
+func (s *StateStream) States() <-chan state.State {
+	return s.states
+}
+
+// SetMasters makes the stream subscribe to the leader among the given
+// masters, ordered as for ParseState. It isn't goroutine-safe.
+func (s *StateStream) SetMasters(masters []string) {
+	select {
+	case <-s.masters:
+	default:
+	}
+	s.masters <- masters
+}
+
+// Resync makes the stream resubscribe, replacing the state it kept up to date
+// with a full snapshot.
+func (s *StateStream) Resync() {
+	select {
+	case s.resync <- struct{}{}:
+	default:
+	}
+}
+
+// Run streams the state of the cluster, never returning.
+func (s *StateStream) Run() {
+	masters := <-s.masters
+	for {
+		stop := make(chan struct{})
+		done := make(chan error, 1)
+		go func(masters []string) { done <- s.subscribe(masters, stop) }(masters)
+
+		select {
+		case masters = <-s.masters:
+			logging.Verbose.Println("state stream: masters changed; resubscribing")
+		case <-s.resync:
+			logging.Verbose.Println("state stream: resubscribing for a full snapshot")
+		case err := <-done:
+			logging.Error.Printf("state stream: %v; resubscribing in %s", err, streamRetryDelay)
+			select {
+			case masters = <-s.masters:
+			case <-s.resync:
+			case <-time.After(streamRetryDelay):
+			}
+			continue
+		}
+		close(stop)
+		<-done
+	}
+}
+
+// subscribe subscribes to the events of the leader among the given masters,
+// sending the state after each batch of events, until the subscription breaks
+// or stop is closed.
+func (s *StateStream) subscribe(masters []string, stop <-chan struct{}) error {
+	leader, err := s.findLeader(masters)
+	if err != nil {
+		return err
+	}
+	addr, err := upid.Parse(leader)
+	if err != nil {
+		return fmt.Errorf("leader %q: %v", leader, err)
+	}
+
+	codec := newOperatorCodec(s.config.StateEncoding)
+	req, err := codec.request(masterURL(s.config, addr.Host, addr.Port, operator.Path), operator.Subscribe)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		errorutil.Ignore(resp.Body.Close)
+		return fmt.Errorf("%s call to %s: %s", operator.Subscribe, req.URL.Host, resp.Status)
+	}
+	logging.Verbose.Printf("state stream: subscribed to %s", leader)
+
+	// the body is closed to stop reading it, be it on stop or when
+	// heartbeats are missed.
+	var closeOnce, timeoutOnce sync.Once
+	closeBody := func() { closeOnce.Do(func() { errorutil.Ignore(resp.Body.Close) }) }
+	defer closeBody()
+	timedOut := make(chan struct{})
+	timeout := time.Duration(s.config.StateTimeoutSeconds) * time.Second
+	if timeout < missedHeartbeats*heartbeatInterval {
+		timeout = missedHeartbeats * heartbeatInterval
+	}
+	watchdog := time.AfterFunc(timeout, func() {
+		timeoutOnce.Do(func() { close(timedOut) })
+		closeBody()
+	})
+	defer watchdog.Stop()
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-stop:
+			closeBody()
+		case <-finished:
+		}
+	}()
+
+	var snapshot *operator.GetStateResponse
+	var changed bool
+	r := operator.NewRecordIOReader(resp.Body)
+	for {
+		data, err := r.ReadRecord()
+		if err != nil {
+			select {
+			case <-stop:
+				return nil
+			case <-timedOut:
+				return fmt.Errorf("no events from %s for %s", leader, timeout)
+			default:
+			}
+			if err == io.EOF {
+				return fmt.Errorf("subscription to %s ended", leader)
+			}
+			return err
+		}
+		watchdog.Reset(timeout)
+
+		var e operator.Event
+		if err := codec.unmarshal(data, &e); err != nil {
+			return fmt.Errorf("%s event: %v", operator.Subscribe, err)
+		}
+		if e.Type == operator.EventUnknown {
+			// events added by later Mesos versions are skipped rather
+			// than breaking the subscription.
+			logging.Verbose.Println("state stream: skipped event of unknown type")
+		} else {
+			logging.VeryVerbose.Printf("state stream: %s event", e.Type)
+			if e.Subscribed != nil {
+				snapshot = &operator.GetStateResponse{}
+				if hb := e.Subscribed.HeartbeatIntervalSeconds; hb > 0 {
+					timeout = missedHeartbeats * time.Duration(hb*float64(time.Second))
+					watchdog.Reset(timeout)
+				}
+			}
+			if snapshot == nil {
+				return errors.New("no SUBSCRIBED event")
+			}
+			snapshot.Apply(&e)
+			changed = changed || e.Type != operator.EventHeartbeat
+		}
+
+		// batches of events arrive together, so the state is only sent
+		// once they're all applied.
+		if r.Buffered() > 0 || !changed {
+			continue
+		}
+		changed = false
+		sj, err := snapshot.State(leader)
+		if err != nil {
+			return err
+		}
+		s.send(sj)
+	}
+}
+
+// send sends the given state on the States channel, replacing any state not
+// received yet. States are held until StreamIntervalSeconds elapse since the
+// last one sent, replaced by any later one.
+func (s *StateStream) send(sj state.State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = &sj
+	if s.timer != nil {
+		return // already held
+	}
+	if wait := s.last.Add(s.interval).Sub(time.Now()); wait > 0 {
+		s.timer = time.AfterFunc(wait, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.timer = nil
+			s.flush()
+		})
+		return
+	}
+	s.flush()
+}
+
+// flush sends the pending state on the States channel. Callers must hold s.mu.
+func (s *StateStream) flush() {
+	select {
+	case <-s.states:
+	default:
+	}
+	s.states <- *s.pending
+	s.pending, s.last = nil, time.Now()
+}
+
+// findLeader returns the PID of the leading master, as told by the first of
+// the given masters answering the GET_MASTER call.
+func (s *StateStream) findLeader(masters []string) (string, error) {
+	rg := NewRecordGenerator(
+		time.Duration(s.config.StateTimeoutSeconds)*time.Second,
+		WithConfig(s.config),
+		WithTransport(s.client.Transport),
+	)
+	for _, master := range masters {
+		if master == "" {
+			continue
+		}
+		ip, port, err := getProto(master)
+		if err != nil {
+			logging.Error.Println(err)
+			continue
+		}
+		r, err := rg.callOperatorAPI(ip, port, operator.GetMaster)
+		if err != nil {
+			logging.Verbose.Printf("state stream: %v", err)
+			continue
+		}
+		if r.GetMaster != nil {
+			return r.GetMaster.MasterInfo.Leader(), nil
+		}
+	}
+	return "", errors.New("no master")
+}
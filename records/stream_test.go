@@ -0,0 +1,134 @@
+package records
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mesosphere/mesos-dns/records/state"
+	"github.com/mesosphere/mesos-dns/records/state/operator"
+)
+
+func TestStateStream(t *testing.T) {
+	snapshot := operator.Event{Type: operator.EventSubscribed, Subscribed: &operator.Subscribed{
+		GetState: &operator.GetStateResponse{
+			GetFrameworks: operator.GetFrameworksResponse{Frameworks: []operator.Framework{{
+				FrameworkInfo: operator.FrameworkInfo{ID: operator.ID{Value: "fw0"}, Name: "marathon"},
+			}}},
+			GetAgents: operator.GetAgentsResponse{Agents: []operator.Agent{{
+				AgentInfo: operator.AgentInfo{ID: operator.ID{Value: "s0"}, Hostname: "10.0.0.2"},
+			}}},
+		},
+		HeartbeatIntervalSeconds: 15,
+	}}
+	events := make(chan operator.Event)
+	unknown := make(chan string)
+	subscriptions := make(chan struct{}, 10)
+	quit := make(chan struct{})
+
+	var leader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var call operator.Call
+		if err := json.NewDecoder(req.Body).Decode(&call); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch call.Type {
+		case operator.GetMaster:
+			_ = json.NewEncoder(w).Encode(operator.Response{GetMaster: &operator.GetMasterResponse{
+				MasterInfo: operator.MasterInfo{PID: leader},
+			}})
+		case operator.Subscribe:
+			subscriptions <- struct{}{}
+			writeData := func(data []byte) {
+				_, _ = fmt.Fprintf(w, "%d\n%s", len(data), data)
+				w.(http.Flusher).Flush()
+			}
+			write := func(e operator.Event) {
+				data, _ := json.Marshal(e)
+				writeData(data)
+			}
+			write(snapshot)
+			closed := w.(http.CloseNotifier).CloseNotify()
+			for {
+				select {
+				case e := <-events:
+					write(e)
+				case data := <-unknown:
+					writeData([]byte(data))
+				case <-closed:
+					return
+				case <-quit:
+					return
+				}
+			}
+		default:
+			http.Error(w, "unexpected call", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	defer close(quit)
+	leader = "master@" + server.Listener.Addr().String()
+
+	c := NewConfig()
+	c.StateAPI, c.StateUpdates = OperatorStateAPI, StreamStateUpdates
+	transport, err := NewMasterTransport(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStateStream(c, transport)
+	go s.Run()
+	s.SetMasters([]string{"", server.Listener.Addr().String()})
+
+	next := func() state.State {
+		select {
+		case sj := <-s.States():
+			return sj
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the state")
+		}
+		return state.State{}
+	}
+
+	sj := next()
+	if sj.Leader != leader || len(sj.Frameworks) != 1 || len(sj.Frameworks[0].Tasks) != 0 || len(sj.Slaves) != 1 {
+		t.Fatalf("snapshot: got state %+v", sj)
+	}
+
+	// events received within StreamIntervalSeconds of the last state sent
+	// are applied together, without resubscribing, and events of unknown
+	// types are skipped
+	unknown <- `{"type": "AGENT_UPDATED", "agent_updated": {"agent": {}}}`
+	for _, id := range []string{"web.1", "web.2", "web.3"} {
+		events <- operator.Event{Type: operator.EventTaskAdded, TaskAdded: &operator.TaskAdded{Task: operator.Task{
+			Name:        "web",
+			TaskID:      operator.ID{Value: id},
+			FrameworkID: operator.ID{Value: "fw0"},
+			AgentID:     operator.ID{Value: "s0"},
+			State:       1,
+		}}}
+	}
+	if sj = next(); len(sj.Frameworks[0].Tasks) != 3 || sj.Frameworks[0].Tasks[2].ID != "web.3" {
+		t.Fatalf("TASK_ADDED: got state %+v", sj)
+	}
+	select {
+	case sj = <-s.States():
+		t.Fatalf("TASK_ADDED: got another state %+v", sj)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := len(subscriptions); got != 1 {
+		t.Errorf("got %d subscriptions, want 1", got)
+	}
+
+	// resyncing replaces the state with a new snapshot
+	s.Resync()
+	if sj = next(); len(sj.Frameworks[0].Tasks) != 0 {
+		t.Fatalf("resync: got state %+v", sj)
+	}
+	if got := len(subscriptions); got != 2 {
+		t.Errorf("got %d subscriptions, want 2", got)
+	}
+}
@@ -0,0 +1,154 @@
+package records
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NameTemplate declares a name generated for every task, relative to the
+// domain. Placeholders in braces are replaced with the task's attributes:
+//
+//	{name}                 task name, or DiscoveryInfo name if defined
+//	{framework}            framework name
+//	{id}                   hashed task ID
+//	{agent}                agent ID tail
+//	{label:KEY}            value of the task label KEY
+//	{discovery:FIELD}      DiscoveryInfo field: name, version, location or environment
+//	{attribute:NAME}       value of the attribute NAME of the task's agent
+//
+// The literal text between placeholders is lowercased and must form valid
+// labels. Names with placeholders which have no value for a task are
+// skipped.
+type NameTemplate struct {
+	Name string
+	// Target selects the addresses of the generated A and AAAA records:
+	// "task" for the task's IPs or "agent" for the IPs of its agent.
+	Target string
+}
+
+// Name template targets.
+const (
+	TaskTarget  = "task"
+	AgentTarget = "agent"
+)
+
+// defaultNameTemplates are the names generated for tasks unless configured
+// otherwise, in addition to their canonical names.
+var defaultNameTemplates = []NameTemplate{
+	{"{name}.{framework}", TaskTarget},
+	{"{name}", TaskTarget},
+	{"{name}.{framework}.slave", AgentTarget},
+	{"{name}.slave", AgentTarget},
+}
+
+// nameTemplate is the parsed form of a NameTemplate.
+type nameTemplate struct {
+	parts  []templatePart
+	target string
+}
+
+// templatePart is either a literal string or a placeholder of the given kind
+// with an optional argument.
+type templatePart struct {
+	literal   string
+	kind, arg string
+}
+
+// templateVars looks up the value of the placeholder of the given kind and
+// argument.
+type templateVars func(kind, arg string) (string, bool)
+
+// parseNameTemplate parses the given NameTemplate.
+func parseNameTemplate(t NameTemplate) (nameTemplate, error) {
+	nt := nameTemplate{target: t.Target}
+	switch t.Target {
+	case TaskTarget, AgentTarget:
+	default:
+		return nt, fmt.Errorf("invalid target %q", t.Target)
+	}
+	if t.Name == "" {
+		return nt, fmt.Errorf("empty name")
+	}
+
+	for s := t.Name; s != ""; {
+		i := strings.IndexAny(s, "{}")
+		if i < 0 {
+			nt.parts = append(nt.parts, templatePart{literal: strings.ToLower(s)})
+			break
+		}
+		if s[i] == '}' {
+			return nt, fmt.Errorf("unexpected '}' in %q", t.Name)
+		}
+		if i > 0 {
+			nt.parts = append(nt.parts, templatePart{literal: strings.ToLower(s[:i])})
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return nt, fmt.Errorf("unterminated placeholder in %q", t.Name)
+		}
+		p, err := parsePlaceholder(s[i+1 : i+j])
+		if err != nil {
+			return nt, err
+		}
+		nt.parts = append(nt.parts, p)
+		s = s[i+j+1:]
+	}
+	return nt, nil
+}
+
+func parsePlaceholder(s string) (templatePart, error) {
+	kind, arg := s, ""
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		kind, arg = s[:i], s[i+1:]
+	}
+	switch kind {
+	case "name", "framework", "id", "agent":
+		if kind == s {
+			return templatePart{kind: kind}, nil
+		}
+	case "label", "attribute":
+		if arg != "" {
+			return templatePart{kind: kind, arg: arg}, nil
+		}
+	case "discovery":
+		switch arg {
+		case "name", "version", "location", "environment":
+			return templatePart{kind: kind, arg: arg}, nil
+		}
+	}
+	return templatePart{}, fmt.Errorf("invalid placeholder {%s}", s)
+}
+
+// expand returns the name of the template with its placeholders replaced by
+// the given vars. returns false if any placeholder has no value.
+func (nt nameTemplate) expand(vars templateVars) (string, bool) {
+	var name []byte
+	for _, p := range nt.parts {
+		if p.kind == "" {
+			name = append(name, p.literal...)
+			continue
+		}
+		v, ok := vars(p.kind, p.arg)
+		if !ok || v == "" {
+			return "", false
+		}
+		name = append(name, v...)
+	}
+	return string(name), true
+}
+
+// parseNameTemplates parses the given NameTemplates, falling back to the
+// default ones if none are given. Invalid templates are skipped; they're
+// rejected by config validation beforehand.
+func parseNameTemplates(ts []NameTemplate) []nameTemplate {
+	if len(ts) == 0 {
+		ts = defaultNameTemplates
+	}
+	parsed := make([]nameTemplate, 0, len(ts))
+	for _, t := range ts {
+		if nt, err := parseNameTemplate(t); err == nil {
+			parsed = append(parsed, nt)
+		}
+	}
+	return parsed
+}
@@ -0,0 +1,62 @@
+package records
+
+import (
+	"testing"
+)
+
+func TestNameTemplate(t *testing.T) {
+	vars := func(kind, arg string) (string, bool) {
+		switch kind + ":" + arg {
+		case "name:":
+			return "web", true
+		case "framework:":
+			return "marathon", true
+		case "label:team":
+			return "core", true
+		case "attribute:zone":
+			return "b", true
+		case "discovery:environment":
+			return "", false
+		}
+		return "", false
+	}
+	for i, tt := range []struct {
+		tmpl  string
+		name  string
+		valid bool
+		ok    bool
+	}{
+		{"{name}.{framework}", "web.marathon", true, true},
+		{"{name}", "web", true, true},
+		{"app-{name}.{label:team}.svc", "app-web.core.svc", true, true},
+		{"{name}.{label:owner}", "", true, false},
+		{"{name}.{discovery:environment}", "", true, false},
+		{"{name}.{attribute:zone}", "web.b", true, true},
+		{"{name}.{attribute:rack}", "", true, false},
+		{"static", "static", true, true},
+		{"App-{name}.SVC", "app-web.svc", true, true},
+		{"", "", false, false},
+		{"{name", "", false, false},
+		{"name}", "", false, false},
+		{"{task}", "", false, false},
+		{"{label:}", "", false, false},
+		{"{attribute:}", "", false, false},
+		{"{name:x}", "", false, false},
+		{"{discovery:ports}", "", false, false},
+	} {
+		nt, err := parseNameTemplate(NameTemplate{Name: tt.tmpl, Target: TaskTarget})
+		if (err == nil) != tt.valid {
+			t.Errorf("test #%d: parseNameTemplate(%q): got err %v, want valid %v", i, tt.tmpl, err, tt.valid)
+			continue
+		} else if err != nil {
+			continue
+		}
+		if name, ok := nt.expand(vars); ok != tt.ok || name != tt.name {
+			t.Errorf("test #%d: expand(%q): got (%q, %v), want (%q, %v)", i, tt.tmpl, name, ok, tt.name, tt.ok)
+		}
+	}
+
+	if _, err := parseNameTemplate(NameTemplate{Name: "{name}", Target: "slave"}); err == nil {
+		t.Error("parseNameTemplate: want error for invalid target")
+	}
+}
@@ -0,0 +1,252 @@
+package records
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mesosphere/mesos-dns/errorutil"
+	"github.com/mesosphere/mesos-dns/logging"
+)
+
+// Credentials holds the principal and secret of HTTP basic authentication.
+// The secret is redacted when marshalled, so that serving the Config doesn't
+// disclose it.
+type Credentials struct {
+	Principal string
+	Secret    string
+}
+
+// MarshalJSON implements the json.Marshaler interface for Credentials.
+func (c Credentials) MarshalJSON() ([]byte, error) {
+	secret := c.Secret
+	if secret != "" {
+		secret = "REDACTED"
+	}
+	return json.Marshal(struct{ Principal, Secret string }{c.Principal, secret})
+}
+
+// NewMasterTransport returns the http.RoundTripper of requests to Mesos
+// masters: over TLS with the configured certificates if MesosHTTPSOn is set,
+// and authenticated as selected by MesosAuthentication. Connections and
+// response headers time out after StateTimeoutSeconds.
+func NewMasterTransport(c Config) (http.RoundTripper, error) {
+	tlsConfig, err := newMasterTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(c.StateTimeoutSeconds) * time.Second
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		Dial:                  (&net.Dialer{Timeout: timeout}).Dial,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+	}
+
+	switch c.MesosAuthentication {
+	case BasicMesosAuthentication:
+		return &authTransport{base: t, auth: basicAuth(c.MesosCredentials)}, nil
+	case TokenMesosAuthentication:
+		return &authTransport{base: t, auth: &tokenFile{path: c.MesosTokenFile}}, nil
+	default:
+		return t, nil
+	}
+}
+
+// newMasterTLSConfig returns the TLS configuration of requests to masters,
+// or nil unless MesosHTTPSOn is set.
+func newMasterTLSConfig(c Config) (*tls.Config, error) {
+	if !c.MesosHTTPSOn {
+		return nil, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: c.MesosInsecureSkipVerify}
+	if c.CACertFile != "" {
+		pem, err := ioutil.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", c.CACertFile)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// masterURL returns the URL of the given path of the given master, using
+// HTTPS if MesosHTTPSOn is set.
+func masterURL(c Config, ip, port, path string) url.URL {
+	scheme := "http"
+	if c.MesosHTTPSOn {
+		scheme = "https"
+	}
+	return url.URL{Scheme: scheme, Host: net.JoinHostPort(ip, port), Path: path}
+}
+
+// authenticator authenticates requests to masters.
+type authenticator interface {
+	// authenticate sets the credentials of the given request.
+	authenticate(req *http.Request) error
+	// renew is called when masters reject the credentials, and reports
+	// whether they changed since, in which case the request is retried.
+	renew() bool
+}
+
+// authTransport authenticates the requests it sends with its authenticator,
+// retrying rejected ones once if their credentials could be renewed.
+type authTransport struct {
+	base http.RoundTripper
+	auth authenticator
+}
+
+// RoundTrip implements the http.RoundTripper interface. Request bodies, e.g.
+// of operator API calls, are read beforehand so that they can be sent again.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		errorutil.Ignore(req.Body.Close)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp, err := t.send(req, body)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !t.auth.renew() {
+		return resp, err
+	}
+	errorutil.Ignore(resp.Body.Close)
+	logging.Verbose.Printf("retrying request to %s with renewed credentials", req.URL.Host)
+	return t.send(req, body)
+}
+
+// send sends an authenticated copy of the given request with the given body,
+// since RoundTrippers mustn't modify requests.
+func (t *authTransport) send(req *http.Request, body []byte) (*http.Response, error) {
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header))
+	for k, vs := range req.Header {
+		r.Header[k] = append([]string(nil), vs...)
+	}
+	if req.Body != nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if err := t.auth.authenticate(r); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(r)
+}
+
+// basicAuth authenticates requests with HTTP basic authentication.
+type basicAuth Credentials
+
+func (a basicAuth) authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Principal, a.Secret)
+	return nil
+}
+
+func (a basicAuth) renew() bool { return false }
+
+// tokenFile authenticates requests with the bearer token held by a file. The
+// file is re-read whenever it changes, its token expires (as told by the exp
+// claim of JSON Web Tokens) or masters reject its token, so that the token
+// can be renewed by another process. It's safe for concurrent use.
+type tokenFile struct {
+	path    string
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	expires time.Time
+}
+
+func (f *tokenFile) authenticate(req *http.Request) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	expired := !f.expires.IsZero() && !time.Now().Before(f.expires)
+	if f.token == "" || expired || !fi.ModTime().Equal(f.modTime) {
+		if err = f.read(); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	return nil
+}
+
+func (f *tokenFile) renew() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	token := f.token
+	if err := f.read(); err != nil {
+		logging.Error.Printf("cannot renew token: %v", err)
+		return false
+	}
+	return f.token != token
+}
+
+// read reads the token of the file. Callers must hold f.mu.
+func (f *tokenFile) read() error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	token := string(bytes.TrimSpace(data))
+	if token == "" {
+		return errors.New("no token in " + f.path)
+	}
+	f.token, f.modTime, f.expires = token, fi.ModTime(), tokenExpiry(token)
+	if !f.expires.IsZero() && !time.Now().Before(f.expires) {
+		logging.Error.Printf("token in %s expired at %s", f.path, f.expires)
+	}
+	return nil
+}
+
+// tokenExpiry returns the expiry of the given token if it's a JSON Web Token
+// with an exp claim, or the zero time.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(claims.Exp), 0)
+}
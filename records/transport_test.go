@@ -0,0 +1,236 @@
+package records
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewMasterTransport_TLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// the server requires client certificates signed by the client's
+	// self-signed certificate
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(`{"leader": "master@10.0.0.1:5050"}`))
+	}))
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: x509.NewCertPool()}
+	server.TLS.ClientCAs.AddCert(clientCert)
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.TLS.Certificates[0].Certificate[0]})
+	if err := ioutil.WriteFile(caFile, ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	for i, tt := range []struct {
+		ca, cert, key string
+		skipVerify    bool
+		ok            bool
+	}{
+		{caFile, certFile, keyFile, false, true},
+		{"", certFile, keyFile, true, true},
+		{"", certFile, keyFile, false, false}, // unknown CA
+		{caFile, "", "", false, false},        // no client certificate
+	} {
+		c := NewConfig()
+		c.MesosHTTPSOn, c.MesosInsecureSkipVerify = true, tt.skipVerify
+		c.CACertFile, c.CertFile, c.KeyFile = tt.ca, tt.cert, tt.key
+		transport, err := NewMasterTransport(c)
+		if err != nil {
+			t.Fatalf("test #%d: %v", i, err)
+		}
+		rg := NewRecordGenerator(time.Second, WithConfig(c), WithTransport(transport))
+		sj, err := rg.loadFromMaster(host, port)
+		if ok := err == nil && sj.Leader == "master@10.0.0.1:5050"; ok != tt.ok {
+			t.Errorf("test #%d: got state %+v, err %v; want success %v", i, sj, err, tt.ok)
+		}
+	}
+}
+
+func TestNewMasterTransport_Authentication(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	var authorized string
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		if req.Header.Get("Authorization") != authorized {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	do := func(transport http.RoundTripper) int {
+		req, err := http.NewRequest("POST", server.URL, bytes.NewReader([]byte("call")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&http.Client{Transport: transport}).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	c := NewConfig()
+	c.MesosAuthentication = BasicMesosAuthentication
+	c.MesosCredentials = Credentials{Principal: "dns", Secret: "s3cret"}
+	transport, err := NewMasterTransport(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized = "Basic " + base64.StdEncoding.EncodeToString([]byte("dns:s3cret"))
+	if got := do(transport); got != http.StatusOK {
+		t.Errorf("basic authentication: got status %d", got)
+	}
+
+	path := filepath.Join(dir, "token")
+	write := func(token string, mtime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a", time.Unix(1, 0))
+	c.MesosAuthentication, c.MesosTokenFile = TokenMesosAuthentication, path
+	if transport, err = NewMasterTransport(c); err != nil {
+		t.Fatal(err)
+	}
+
+	authorized = "Bearer a"
+	if got := do(transport); got != http.StatusOK {
+		t.Errorf("token: got status %d", got)
+	}
+
+	// changed files are re-read
+	authorized = "Bearer b"
+	write("b", time.Unix(2, 0))
+	if got := do(transport); got != http.StatusOK {
+		t.Errorf("changed token: got status %d", got)
+	}
+
+	// rejected tokens are re-read and their requests retried once
+	authorized = "Bearer c"
+	write("c", time.Unix(2, 0))
+	bodies = nil
+	if got := do(transport); got != http.StatusOK || len(bodies) != 2 || bodies[1] != "call" {
+		t.Errorf("renewed token: got status %d, bodies %q", got, bodies)
+	}
+	authorized = "Bearer d"
+	bodies = nil
+	if got := do(transport); got != http.StatusUnauthorized || len(bodies) != 1 {
+		t.Errorf("rejected token: got status %d, bodies %q", got, bodies)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	jwt := func(claims string) string {
+		enc := base64.RawURLEncoding.EncodeToString
+		return enc([]byte(`{"alg":"none"}`)) + "." + enc([]byte(claims)) + ".sig"
+	}
+	for i, tt := range []struct {
+		token string
+		want  time.Time
+	}{
+		{jwt(`{"uid":"dns","exp":1500000000}`), time.Unix(1500000000, 0)},
+		{jwt(`{"uid":"dns"}`), time.Time{}},
+		{"opaque-token", time.Time{}},
+		{"a.b.c", time.Time{}},
+	} {
+		if got := tokenExpiry(tt.token); !got.Equal(tt.want) {
+			t.Errorf("test #%d: got expiry %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestCredentials_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(Credentials{Principal: "dns", Secret: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"Principal":"dns","Secret":"REDACTED"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	var c Credentials
+	if err := json.Unmarshal([]byte(`{"Principal":"dns","Secret":"s3cret"}`), &c); err != nil || c.Secret != "s3cret" {
+		t.Errorf("got credentials %+v (err %v)", c, err)
+	}
+}
+
+// writeClientCert writes a self-signed client certificate and its key to the
+// given directory, returning their paths along with the certificate.
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mesos-dns"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	for path, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile, cert
+}
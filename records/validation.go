@@ -3,6 +3,9 @@ package records
 import (
 	"fmt"
 	"net"
+	"strings"
+
+	"github.com/miekg/dns"
 )
 
 func validateEnabledServices(c *Config) error {
@@ -82,3 +85,321 @@ func validateIPSources(srcs []string) error {
 
 	return nil
 }
+
+// validateIPMode checks validity of the ip mode
+func validateIPMode(mode string) error {
+	switch mode {
+	case IPv4Mode, IPv6Mode, DualMode:
+		return nil
+	default:
+		return fmt.Errorf("invalid ip mode %q", mode)
+	}
+}
+
+// validateStateAPI checks validity of the state API and its encoding
+func validateStateAPI(api, encoding string) error {
+	switch api {
+	case LegacyStateAPI, OperatorStateAPI:
+	default:
+		return fmt.Errorf("invalid state API %q", api)
+	}
+	switch encoding {
+	case JSONStateEncoding, ProtobufStateEncoding:
+		return nil
+	default:
+		return fmt.Errorf("invalid state encoding %q", encoding)
+	}
+}
+
+// validateStateUpdates checks that the given state updates mode is known and
+// that streamed updates are read from the v1 operator API.
+func validateStateUpdates(updates, api string) error {
+	switch updates {
+	case PollStateUpdates:
+		return nil
+	case StreamStateUpdates:
+		if api != OperatorStateAPI {
+			return fmt.Errorf("stream updates require the %q state API", OperatorStateAPI)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid state updates %q", updates)
+	}
+}
+
+// validateMesosTLS checks that the TLS certificates and keys of requests to
+// masters load, and that they're only set along with MesosHTTPSOn.
+func validateMesosTLS(c Config) error {
+	if !c.MesosHTTPSOn {
+		if c.CACertFile != "" || c.CertFile != "" || c.KeyFile != "" || c.MesosInsecureSkipVerify {
+			return fmt.Errorf("TLS settings require MesosHTTPSOn")
+		}
+		return nil
+	}
+	_, err := newMasterTLSConfig(c)
+	return err
+}
+
+// validateMesosAuthentication checks that the given authentication mode is
+// known and that its credentials are set.
+func validateMesosAuthentication(mode string, creds Credentials, tokenPath string) error {
+	switch mode {
+	case NoMesosAuthentication:
+		return nil
+	case BasicMesosAuthentication:
+		if creds.Principal == "" {
+			return fmt.Errorf("basic authentication requires MesosCredentials")
+		}
+		return nil
+	case TokenMesosAuthentication:
+		if tokenPath == "" {
+			return fmt.Errorf("token authentication requires MesosTokenFile")
+		}
+		return (&tokenFile{path: tokenPath}).read()
+	default:
+		return fmt.Errorf("invalid Mesos authentication %q", mode)
+	}
+}
+
+// validateHostCache checks that the hostname cache durations aren't negative
+// and that there's at least one lookup worker.
+func validateHostCache(ttl, negativeTTL, workers int) error {
+	if ttl < 0 || negativeTTL < 0 {
+		return fmt.Errorf("cache durations must not be negative")
+	}
+	if workers < 1 {
+		return fmt.Errorf("HostLookupWorkers must be positive")
+	}
+	return nil
+}
+
+// validateStaticFiles checks that the given static files can be read
+func validateStaticFiles(paths []string, domain string) error {
+	for _, path := range paths {
+		if _, err := readStaticFile(path, domain+"."); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// taskStates holds the known Mesos task states.
+var taskStates = map[string]struct{}{
+	"TASK_STAGING":          {},
+	"TASK_STARTING":         {},
+	"TASK_RUNNING":          {},
+	"TASK_KILLING":          {},
+	"TASK_FINISHED":         {},
+	"TASK_FAILED":           {},
+	"TASK_KILLED":           {},
+	"TASK_ERROR":            {},
+	"TASK_LOST":             {},
+	"TASK_DROPPED":          {},
+	"TASK_UNREACHABLE":      {},
+	"TASK_GONE":             {},
+	"TASK_GONE_BY_OPERATOR": {},
+	"TASK_UNKNOWN":          {},
+}
+
+// validateTaskStates checks that at least one task state is given, and that
+// every one is a known Mesos task state. duplicate states are not allowed.
+func validateTaskStates(states []string) error {
+	if len(states) == 0 {
+		return fmt.Errorf("no task states specified")
+	}
+	seen := make(map[string]struct{}, len(states))
+	for _, st := range states {
+		if _, ok := taskStates[st]; !ok {
+			return fmt.Errorf("invalid task state %q", st)
+		}
+		if _, ok := seen[st]; ok {
+			return fmt.Errorf("duplicate task state specified: %v", st)
+		}
+		seen[st] = struct{}{}
+	}
+	return nil
+}
+
+// validateHealthPolicy checks validity of the health policy
+func validateHealthPolicy(policy string) error {
+	switch policy {
+	case AllPolicy, HealthyPolicy, HealthyFirstPolicy:
+		return nil
+	default:
+		return fmt.Errorf("invalid health policy %q", policy)
+	}
+}
+
+// validateCollisionPolicy checks validity of the collision policy
+func validateCollisionPolicy(policy string) error {
+	switch policy {
+	case MergePolicy, FirstWinsPolicy, DropPolicy:
+		return nil
+	default:
+		return fmt.Errorf("invalid collision policy %q", policy)
+	}
+}
+
+// validateAliasMode checks validity of the alias mode
+func validateAliasMode(mode string) error {
+	switch mode {
+	case DirectAliasMode, CNAMEAliasMode:
+		return nil
+	default:
+		return fmt.Errorf("invalid alias mode %q", mode)
+	}
+}
+
+// validateIPFilters checks that every IP filter applies to a known record
+// class and lists only valid CIDRs.
+func validateIPFilters(fs map[string]IPFilter) error {
+	for class, f := range fs {
+		if !contains(recordClasses, class) {
+			return fmt.Errorf("invalid record class %q", class)
+		}
+		for _, cidr := range append(append([]string{}, f.Allow...), f.Deny...) {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("illegal CIDR specified for %s records: %q", class, cidr)
+			}
+		}
+	}
+	return nil
+}
+
+// validateReverseZones checks that every reverse zone is a CIDR on a label
+// boundary. duplicate zones are not allowed.
+func validateReverseZones(cidrs []string) error {
+	zones := make(map[string]struct{}, len(cidrs))
+	for _, cidr := range cidrs {
+		zone, err := ReverseZone(cidr)
+		if err != nil {
+			return fmt.Errorf("illegal reverse zone %q: %v", cidr, err)
+		}
+		if _, found := zones[zone]; found {
+			return fmt.Errorf("duplicate reverse zone specified: %v", cidr)
+		}
+		zones[zone] = struct{}{}
+	}
+	return nil
+}
+
+// validateSRVWeightResource checks validity of the SRV weight resource
+func validateSRVWeightResource(resource string) error {
+	switch resource {
+	case "", "cpus", "mem", "disk":
+		return nil
+	default:
+		return fmt.Errorf("invalid resource %q", resource)
+	}
+}
+
+// validateTTLs checks that every TTL applies to a known record class and is
+// positive.
+func validateTTLs(ttls map[string]uint32) error {
+	for class, ttl := range ttls {
+		if !contains(recordClasses, class) {
+			return fmt.Errorf("invalid record class %q", class)
+		}
+		if ttl == 0 {
+			return fmt.Errorf("TTL of %s records must be positive", class)
+		}
+	}
+	return nil
+}
+
+// validateNameTemplates checks that every name template is valid and that
+// its literal text forms valid labels, as RFC952 ones if rfc952 is set.
+// duplicate templates are not allowed.
+func validateNameTemplates(ts []NameTemplate, rfc952 bool) error {
+	seen := make(map[NameTemplate]struct{}, len(ts))
+	for _, t := range ts {
+		nt, err := parseNameTemplate(t)
+		if err != nil {
+			return fmt.Errorf("illegal name template %q: %v", t.Name, err)
+		}
+		// placeholders expand to valid labels, so any letter stands for
+		// them when checking the labels of the literal text.
+		name, _ := nt.expand(func(string, string) (string, bool) { return "x", true })
+		for _, label := range strings.Split(name, ".") {
+			if !validLabel(label, rfc952) {
+				return fmt.Errorf("illegal name template %q: invalid label %q", t.Name, label)
+			}
+		}
+		if _, ok := seen[t]; ok {
+			return fmt.Errorf("duplicate name template %q", t.Name)
+		}
+		seen[t] = struct{}{}
+	}
+	return nil
+}
+
+// validLabel reports whether the given lowercase label is a valid RFC1123
+// label, or RFC952 one if rfc952 is set: letters, digits and hyphens, not
+// starting or ending with a hyphen, nor with a digit for RFC952 labels.
+func validLabel(label string, rfc952 bool) bool {
+	maxlen := 63
+	if rfc952 {
+		maxlen = 24
+	}
+	if label == "" || len(label) > maxlen || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	if rfc952 && label[0] >= '0' && label[0] <= '9' {
+		return false
+	}
+	for _, c := range label {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateExternalDomain checks that the external domain, if any, is a valid
+// domain name distinct from the cluster domain.
+func validateExternalDomain(ext, domain string) error {
+	if ext == "" {
+		return nil
+	}
+	if _, ok := dns.IsDomainName(ext); !ok {
+		return fmt.Errorf("invalid domain name %q", ext)
+	}
+	if ext == domain {
+		return fmt.Errorf("external domain %q must differ from the cluster domain", ext)
+	}
+	return nil
+}
+
+// validateDiscoveryZones checks that every discovery zone is a known
+// DiscoveryInfo field. duplicate fields are not allowed.
+func validateDiscoveryZones(fields []string) error {
+	seen := make(map[string]struct{}, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "environment", "location", "version":
+		default:
+			return fmt.Errorf("invalid DiscoveryInfo field %q", field)
+		}
+		if _, ok := seen[field]; ok {
+			return fmt.Errorf("duplicate DiscoveryInfo field %q", field)
+		}
+		seen[field] = struct{}{}
+	}
+	return nil
+}
+
+// validateAgentAttributes checks that every agent attribute name is
+// non-empty. duplicate names are not allowed.
+func validateAgentAttributes(names []string) error {
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if name == "" {
+			return fmt.Errorf("empty attribute name")
+		}
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("duplicate attribute name %q", name)
+		}
+		seen[name] = struct{}{}
+	}
+	return nil
+}
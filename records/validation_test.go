@@ -49,6 +49,283 @@ func TestValidateResolvers(t *testing.T) {
 	}
 }
 
+func TestValidateIPFilters(t *testing.T) {
+	for i, tc := range []struct {
+		in    map[string]IPFilter
+		valid bool
+	}{
+		{nil, true},
+		{map[string]IPFilter{}, true},
+		{map[string]IPFilter{"task": {}}, true},
+		{map[string]IPFilter{"task": {Deny: []string{"172.17.0.0/16"}}}, true},
+		{map[string]IPFilter{"agent": {Allow: []string{"10.0.0.0/8", "fd00::/8"}}}, true},
+		{map[string]IPFilter{"slave": {Deny: []string{"172.17.0.0/16"}}}, false},
+		{map[string]IPFilter{"task": {Deny: []string{"172.17.0.1"}}}, false},
+		{map[string]IPFilter{"master": {Allow: []string{"10.0.0.0/33"}}}, false},
+	} {
+		if err := validateIPFilters(tc.in); (err == nil) != tc.valid {
+			t.Errorf("test %d: validateIPFilters(%v): got err %v, want valid %v", i+1, tc.in, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateReverseZones(t *testing.T) {
+	for i, tc := range []validationTest{
+		{nil, true},
+		{[]string{}, true},
+		{[]string{"10.0.0.0/8"}, true},
+		{[]string{"10.0.0.0/8", "fd00::/8"}, true},
+		{[]string{"10.0.0.0/8", "10.1.0.0/8"}, false},
+		{[]string{"172.16.0.0/12"}, false},
+		{[]string{"10.in-addr.arpa."}, false},
+	} {
+		validate(t, i+1, tc, validateReverseZones)
+	}
+}
+
+func TestValidateSRVWeightResource(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		valid bool
+	}{
+		{"", true},
+		{"cpus", true},
+		{"mem", true},
+		{"disk", true},
+		{"gpus", false},
+	} {
+		if err := validateSRVWeightResource(tc.in); (err == nil) != tc.valid {
+			t.Errorf("validateSRVWeightResource(%q): got err %v, want valid %v", tc.in, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateTaskStates(t *testing.T) {
+	for i, tc := range []validationTest{
+		{nil, false},
+		{[]string{"TASK_RUNNING"}, true},
+		{[]string{"TASK_RUNNING", "TASK_KILLING"}, true},
+		{[]string{"TASK_RUNNING", "TASK_RUNNING"}, false},
+		{[]string{"RUNNING"}, false},
+	} {
+		validate(t, i+1, tc, validateTaskStates)
+	}
+}
+
+func TestValidateStateAPI(t *testing.T) {
+	for _, tc := range []struct {
+		api, encoding string
+		valid         bool
+	}{
+		{"state.json", "json", true},
+		{"v1", "json", true},
+		{"v1", "protobuf", true},
+		{"", "json", false},
+		{"v0", "json", false},
+		{"v1", "", false},
+		{"v1", "xml", false},
+	} {
+		if err := validateStateAPI(tc.api, tc.encoding); (err == nil) != tc.valid {
+			t.Errorf("validateStateAPI(%q, %q): got err %v, want valid %v", tc.api, tc.encoding, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateStateUpdates(t *testing.T) {
+	for _, tc := range []struct {
+		updates, api string
+		valid        bool
+	}{
+		{"poll", "state.json", true},
+		{"poll", "v1", true},
+		{"stream", "v1", true},
+		{"stream", "state.json", false},
+		{"", "v1", false},
+		{"push", "v1", false},
+	} {
+		if err := validateStateUpdates(tc.updates, tc.api); (err == nil) != tc.valid {
+			t.Errorf("validateStateUpdates(%q, %q): got err %v, want valid %v", tc.updates, tc.api, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateMesosTLS(t *testing.T) {
+	for i, tc := range []struct {
+		httpsOn       bool
+		ca, cert, key string
+		skipVerify    bool
+		valid         bool
+	}{
+		{false, "", "", "", false, true},
+		{true, "", "", "", false, true},
+		{true, "", "", "", true, true},
+		{false, "", "", "", true, false},
+		{false, "/ca.pem", "", "", false, false},
+		{true, "/missing/ca.pem", "", "", false, false},
+		{true, "", "/missing/cert.pem", "", false, false},
+	} {
+		c := NewConfig()
+		c.MesosHTTPSOn, c.MesosInsecureSkipVerify = tc.httpsOn, tc.skipVerify
+		c.CACertFile, c.CertFile, c.KeyFile = tc.ca, tc.cert, tc.key
+		if err := validateMesosTLS(c); (err == nil) != tc.valid {
+			t.Errorf("test #%d: got err %v, want valid %v", i, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateMesosAuthentication(t *testing.T) {
+	for i, tc := range []struct {
+		mode      string
+		creds     Credentials
+		tokenFile string
+		valid     bool
+	}{
+		{"", Credentials{}, "", true},
+		{"basic", Credentials{Principal: "dns", Secret: "s3cret"}, "", true},
+		{"basic", Credentials{}, "", false},
+		{"token", Credentials{}, "", false},
+		{"token", Credentials{}, "/missing/token", false},
+		{"iam", Credentials{}, "", false},
+	} {
+		if err := validateMesosAuthentication(tc.mode, tc.creds, tc.tokenFile); (err == nil) != tc.valid {
+			t.Errorf("test #%d: got err %v, want valid %v", i, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateHealthPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		valid bool
+	}{
+		{"all", true},
+		{"healthy", true},
+		{"healthy-first", true},
+		{"", false},
+		{"unhealthy", false},
+	} {
+		if err := validateHealthPolicy(tc.in); (err == nil) != tc.valid {
+			t.Errorf("validateHealthPolicy(%q): got err %v, want valid %v", tc.in, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateCollisionPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		valid bool
+	}{
+		{"merge", true},
+		{"first-wins", true},
+		{"drop", true},
+		{"", false},
+		{"last-wins", false},
+	} {
+		if err := validateCollisionPolicy(tc.in); (err == nil) != tc.valid {
+			t.Errorf("validateCollisionPolicy(%q): got err %v, want valid %v", tc.in, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateAliasMode(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		valid bool
+	}{
+		{"direct", true},
+		{"cname", true},
+		{"", false},
+		{"CNAME", false},
+	} {
+		if err := validateAliasMode(tc.in); (err == nil) != tc.valid {
+			t.Errorf("validateAliasMode(%q): got err %v, want valid %v", tc.in, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateTTLs(t *testing.T) {
+	for i, tc := range []struct {
+		in    map[string]uint32
+		valid bool
+	}{
+		{nil, true},
+		{map[string]uint32{"task": 5, "master": 3600}, true},
+		{map[string]uint32{"task": 0}, false},
+		{map[string]uint32{"slave": 60}, false},
+	} {
+		if err := validateTTLs(tc.in); (err == nil) != tc.valid {
+			t.Errorf("test %d: validateTTLs(%v): got err %v, want valid %v", i+1, tc.in, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateNameTemplates(t *testing.T) {
+	for i, tc := range []struct {
+		in     []NameTemplate
+		rfc952 bool
+		valid  bool
+	}{
+		{nil, false, true},
+		{defaultNameTemplates, false, true},
+		{defaultNameTemplates, true, true},
+		{[]NameTemplate{{"{name}.{label:team}", "task"}, {"{name}.{label:team}", "agent"}}, false, true},
+		{[]NameTemplate{{"{name}", "task"}, {"{name}", "task"}}, false, false},
+		{[]NameTemplate{{"{name}", "slave"}}, false, false},
+		{[]NameTemplate{{"{nme}", "task"}}, false, false},
+		{[]NameTemplate{{"{name}.Web", "task"}}, false, true},
+		{[]NameTemplate{{"{name}-v2.{framework}", "task"}}, false, true},
+		{[]NameTemplate{{"{name}.2nd", "task"}}, false, true},
+		{[]NameTemplate{{"{name}.2nd", "task"}}, true, false},
+		{[]NameTemplate{{"{name}..web", "task"}}, false, false},
+		{[]NameTemplate{{"{name}.web.", "task"}}, false, false},
+		{[]NameTemplate{{"{name}.-web", "task"}}, false, false},
+		{[]NameTemplate{{"{name}-.web", "task"}}, false, false},
+		{[]NameTemplate{{"{name}_web", "task"}}, false, false},
+	} {
+		if err := validateNameTemplates(tc.in, tc.rfc952); (err == nil) != tc.valid {
+			t.Errorf("test %d: validateNameTemplates(%v): got err %v, want valid %v", i+1, tc.in, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateExternalDomain(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		valid bool
+	}{
+		{"", true},
+		{"example.com", true},
+		{"mesos", false},
+		{"bad..name", false},
+	} {
+		if err := validateExternalDomain(tc.in, "mesos"); (err == nil) != tc.valid {
+			t.Errorf("validateExternalDomain(%q): got err %v, want valid %v", tc.in, err, tc.valid)
+		}
+	}
+}
+
+func TestValidateDiscoveryZones(t *testing.T) {
+	for i, tc := range []validationTest{
+		{nil, true},
+		{[]string{"environment", "location", "version"}, true},
+		{[]string{"environment", "environment"}, false},
+		{[]string{"name"}, false},
+	} {
+		validate(t, i+1, tc, validateDiscoveryZones)
+	}
+}
+
+func TestValidateAgentAttributes(t *testing.T) {
+	for i, tc := range []validationTest{
+		{nil, true},
+		{[]string{"rack", "zone"}, true},
+		{[]string{"rack", "rack"}, false},
+		{[]string{""}, false},
+	} {
+		validate(t, i+1, tc, validateAgentAttributes)
+	}
+}
+
 type validationTest struct {
 	in    []string
 	valid bool
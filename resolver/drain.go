@@ -0,0 +1,93 @@
+package resolver
+
+import (
+	"time"
+
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/mesosphere/mesos-dns/records"
+)
+
+// drainer keeps the records of tasks which stopped, e.g. because they were
+// killed, for a grace period so that clients with cached
+// answers don't immediately hit dead endpoints. It remembers when each task
+// started draining across reloads. It's not safe for concurrent use.
+type drainer struct {
+	grace time.Duration
+	ttl   uint32
+	tasks map[string]drainingTask
+}
+
+// drainingTask holds the records of a draining task and when it started
+// draining.
+type drainingTask struct {
+	since time.Time
+	rrs   []records.RR
+}
+
+// drain returns the next record set along with the records of the tasks which
+// were published in the previous one but aren't in the next, until their
+// grace period is over. Tasks which are still live, as listed by the given
+// IDs, aren't drained: their records were dropped on purpose, e.g. because
+// they're failing their health checks or their names collide.
+func (d *drainer) drain(prev, next *records.RecordSet, live map[string]struct{}, now time.Time) *records.RecordSet {
+	if d.grace <= 0 {
+		return next
+	}
+	if d.tasks == nil {
+		d.tasks = map[string]drainingTask{}
+	}
+
+	published := map[string]struct{}{}
+	for _, rr := range next.All() {
+		if rr.Origin.TaskID != "" {
+			published[rr.Origin.TaskID] = struct{}{}
+		}
+	}
+	isLive := func(id string) bool {
+		_, ok := live[id]
+		return ok
+	}
+	gone := map[string][]records.RR{}
+	for _, rr := range prev.All() {
+		id := rr.Origin.TaskID
+		if _, ok := published[id]; !ok && id != "" && rr.Class == records.TaskClass && !isLive(id) {
+			gone[id] = append(gone[id], rr)
+		}
+	}
+
+	// forget tasks which are published or live again or whose grace period
+	// is over; the records of the latter are still in the previous set, so
+	// they must not be taken for tasks which just stopped
+	expired := map[string]struct{}{}
+	for id, t := range d.tasks {
+		if _, ok := published[id]; ok || isLive(id) {
+			delete(d.tasks, id)
+		} else if now.Sub(t.since) >= d.grace {
+			logging.Verbose.Printf("drained task %q", id)
+			delete(d.tasks, id)
+			expired[id] = struct{}{}
+		}
+	}
+	for id, rrs := range gone {
+		_, draining := d.tasks[id]
+		if _, ok := expired[id]; ok || draining {
+			continue
+		}
+		if d.ttl > 0 {
+			for i := range rrs {
+				rrs[i].TTL = d.ttl
+			}
+		}
+		d.tasks[id] = drainingTask{since: now, rrs: rrs}
+		logging.Verbose.Printf("draining task %q for %v", id, d.grace)
+	}
+
+	var rrs []records.RR
+	for _, t := range d.tasks {
+		rrs = append(rrs, t.rrs...)
+	}
+	if len(rrs) == 0 {
+		return next
+	}
+	return next.With(rrs...)
+}
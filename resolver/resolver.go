@@ -18,6 +18,7 @@ import (
 	"github.com/mesosphere/mesos-dns/exchanger"
 	"github.com/mesosphere/mesos-dns/logging"
 	"github.com/mesosphere/mesos-dns/records"
+	"github.com/mesosphere/mesos-dns/records/state"
 	"github.com/mesosphere/mesos-dns/util"
 	"github.com/miekg/dns"
 )
@@ -31,12 +32,21 @@ type Resolver struct {
 	rsLock  sync.RWMutex
 	rng     *rand.Rand
 	fwd     exchanger.Forwarder
+	subs    subscribers
+	drain   drainer
+	hosts   *records.HostCache
+	static  *records.StaticFiles
+	// transport sends the requests to masters.
+	transport http.RoundTripper
 }
 
 // New returns a Resolver with the given version and configuration.
 func New(version string, config records.Config) *Resolver {
 	var recordGenerator *records.RecordGenerator
-	recordGenerator = records.NewRecordGenerator(time.Duration(config.StateTimeoutSeconds) * time.Second)
+	recordGenerator = records.NewRecordGenerator(
+		time.Duration(config.StateTimeoutSeconds)*time.Second,
+		records.WithConfig(config),
+	)
 	r := &Resolver{
 		version: version,
 		config:  config,
@@ -45,8 +55,23 @@ func New(version string, config records.Config) *Resolver {
 		// See: https://github.com/golang/go/issues/3611
 		rng:     rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano())}),
 		masters: append([]string{""}, config.Masters...),
+		drain: drainer{
+			grace: time.Duration(config.DrainSeconds) * time.Second,
+			ttl:   config.DrainTTL,
+		},
+		hosts: records.NewHostCache(
+			time.Duration(config.HostCacheSeconds)*time.Second,
+			time.Duration(config.HostNegativeCacheSeconds)*time.Second,
+		),
+		static: records.NewStaticFiles(),
 	}
 
+	transport, err := records.NewMasterTransport(config)
+	if err != nil {
+		logging.Error.Fatalf("cannot set up requests to masters: %v", err)
+	}
+	r.transport = transport
+
 	timeout := 5 * time.Second
 	if config.Timeout != 0 {
 		timeout = time.Duration(config.Timeout) * time.Second
@@ -95,6 +120,14 @@ func (res *Resolver) records() *records.RecordGenerator {
 func (res *Resolver) LaunchDNS() <-chan error {
 	// Handers for Mesos requests
 	dns.HandleFunc(res.config.Domain+".", panicRecover(res.HandleMesos))
+	// Handler for the zone of EXTERNAL visible tasks
+	if res.config.EnforceVisibility && res.config.ExternalDomain != "" {
+		dns.HandleFunc(res.config.ExternalDomain+".", panicRecover(res.HandleMesos))
+	}
+	// Handlers for reverse zones of Mesos addresses
+	for _, zone := range records.ReverseZones(res.config.ReverseZones) {
+		dns.HandleFunc(zone, panicRecover(res.HandleMesos))
+	}
 	// Handler for nonMesos requests
 	dns.HandleFunc(".", panicRecover(res.HandleNonMesos))
 
@@ -133,6 +166,13 @@ func (res *Resolver) Serve(proto string) (<-chan struct{}, <-chan error) {
 	return ch, errCh
 }
 
+// Transport returns the http.RoundTripper of the requests of the Resolver to
+// masters, so that others requesting them share its connections and
+// credentials.
+func (res *Resolver) Transport() http.RoundTripper {
+	return res.transport
+}
+
 // SetMasters sets the given masters.
 // This method is not goroutine-safe.
 func (res *Resolver) SetMasters(masters []string) {
@@ -142,16 +182,17 @@ func (res *Resolver) SetMasters(masters []string) {
 // Reload triggers a new state load from the configured mesos masters.
 // This method is not goroutine-safe.
 func (res *Resolver) Reload() {
-	t := records.NewRecordGenerator(time.Duration(res.config.StateTimeoutSeconds) * time.Second)
+	t := records.NewRecordGenerator(
+		time.Duration(res.config.StateTimeoutSeconds)*time.Second,
+		records.WithHostCache(res.hosts),
+		records.WithStaticFiles(res.static),
+		records.WithTransport(res.transport),
+		records.WithKnownCollisions(res.collisions()),
+	)
 	err := t.ParseState(res.config, res.masters...)
 
 	if err == nil {
-		timestamp := uint32(time.Now().Unix())
-		// may need to refactor for fairness
-		res.rsLock.Lock()
-		defer res.rsLock.Unlock()
-		atomic.StoreUint32(&res.config.SOASerial, timestamp)
-		res.rs = t
+		res.swap(t)
 	} else {
 		logging.Error.Printf("Warning: Error generating records: %v; keeping old DNS state", err)
 	}
@@ -159,36 +200,120 @@ func (res *Resolver) Reload() {
 	logging.PrintCurLog()
 }
 
-// formatSRV returns the SRV resource record for target
-func (res *Resolver) formatSRV(name string, target string) (*dns.SRV, error) {
-	ttl := uint32(res.config.TTL)
+// Update generates the records of the given state of the cluster, e.g. kept
+// up to date with the events of the leading master, instead of loading it.
+// All the records are regenerated rather than patched with the events, so
+// that collisions, aliases and health are resolved as on reloads; callers
+// rate-limit updates, as StateStream does per StreamIntervalSeconds.
+// This method is not goroutine-safe.
+func (res *Resolver) Update(sj state.State) {
+	t := records.NewRecordGenerator(
+		time.Duration(res.config.StateTimeoutSeconds)*time.Second,
+		records.WithHostCache(res.hosts),
+		records.WithStaticFiles(res.static),
+		records.WithTransport(res.transport),
+		records.WithKnownCollisions(res.collisions()),
+	)
+	err := t.LoadState(res.config, sj, res.masters...)
 
-	h, port, err := net.SplitHostPort(target)
-	if err != nil {
-		return nil, errors.New("invalid target")
+	if err == nil {
+		res.swap(t)
+	} else {
+		logging.Error.Printf("Warning: Error generating records: %v; keeping old DNS state", err)
+	}
+
+	logging.PrintCurLog()
+}
+
+// collisions returns the Collisions of the current record set, if any.
+// This method is not goroutine-safe.
+func (res *Resolver) collisions() []records.Collision {
+	if res.rs == nil {
+		return nil
+	}
+	return res.rs.Collisions
+}
+
+// swap replaces the current record set with the given one, logging, counting
+// and publishing the changes between them to subscribers. The records of
+// tasks which stopped are kept while they're draining.
+// This method is not goroutine-safe.
+func (res *Resolver) swap(t *records.RecordGenerator) {
+	now := time.Now()
+	var prevRecords *records.RecordSet
+	if res.rs != nil {
+		prevRecords = res.rs.Records
+	}
+	t.Records = res.drain.drain(prevRecords, t.Records, t.LiveTasks, now)
+
+	// may need to refactor for fairness
+	res.rsLock.Lock()
+	atomic.StoreUint32(&res.config.SOASerial, uint32(now.Unix()))
+	res.rs = t
+	res.rsLock.Unlock()
+
+	d := records.DiffRecords(prevRecords, t.Records)
+	if d.Empty() {
+		return
+	}
+
+	logging.Verbose.Printf("records changed: %d added, %d removed; names: %d added, %d removed",
+		len(d.Added), len(d.Removed), len(d.AddedNames), len(d.RemovedNames))
+	for _, name := range d.AddedNames {
+		logging.Verbose.Println("added name " + name)
+	}
+	for _, name := range d.RemovedNames {
+		logging.Verbose.Println("removed name " + name)
+	}
+	for _, rr := range d.Added {
+		logging.Verbose.Println("added record " + rr.String())
+		logging.CurLog.RecordsAdded.Inc()
 	}
-	p, _ := strconv.Atoi(port)
+	for _, rr := range d.Removed {
+		logging.Verbose.Println("removed record " + rr.String())
+		logging.CurLog.RecordsRemoved.Inc()
+	}
+
+	res.subs.publish(d)
+}
+
+// Subscribe returns a channel on which the changes of every subsequent reload
+// are published, along with a function that cancels the subscription and
+// closes the channel. Changes are dropped if the channel's buffer of the
+// given size is full.
+func (res *Resolver) Subscribe(buffer int) (<-chan records.Diff, func()) {
+	return res.subs.subscribe(buffer)
+}
 
+// ttl returns the TTL of the given record, falling back to the configured
+// TTL if it has none.
+func (res *Resolver) ttl(rr records.RR) uint32 {
+	if rr.TTL != 0 {
+		return rr.TTL
+	}
+	return uint32(res.config.TTL)
+}
+
+// formatSRV returns the SRV resource record of rr under the given name
+func (res *Resolver) formatSRV(name string, rr records.RR) *dns.SRV {
 	return &dns.SRV{
 		Hdr: dns.RR_Header{
 			Name:   name,
 			Rrtype: dns.TypeSRV,
 			Class:  dns.ClassINET,
-			Ttl:    ttl,
+			Ttl:    res.ttl(rr),
 		},
-		Priority: 0,
-		Weight:   0,
-		Port:     uint16(p),
-		Target:   h,
-	}, nil
+		Priority: rr.Priority,
+		Weight:   rr.Weight,
+		Port:     rr.Port,
+		Target:   rr.Target,
+	}
 }
 
-// returns the A resource record for target
-// assumes target is a well formed IPv4 address
-func (res *Resolver) formatA(dom string, target string) (*dns.A, error) {
-	ttl := uint32(res.config.TTL)
-
-	a := net.ParseIP(target)
+// returns the A resource record of rr
+// assumes rr holds a well formed IPv4 address
+func (res *Resolver) formatA(dom string, rr records.RR) (*dns.A, error) {
+	a := rr.IP.To4()
 	if a == nil {
 		return nil, errors.New("invalid target")
 	}
@@ -198,11 +323,95 @@ func (res *Resolver) formatA(dom string, target string) (*dns.A, error) {
 			Name:   dom,
 			Rrtype: dns.TypeA,
 			Class:  dns.ClassINET,
-			Ttl:    ttl},
-		A: a.To4(),
+			Ttl:    res.ttl(rr)},
+		A: a,
+	}, nil
+}
+
+// returns the AAAA resource record of rr
+// assumes rr holds a well formed IPv6 address
+func (res *Resolver) formatAAAA(dom string, rr records.RR) (*dns.AAAA, error) {
+	if len(rr.IP) != net.IPv6len || rr.IP.To4() != nil {
+		return nil, errors.New("invalid target")
+	}
+
+	return &dns.AAAA{
+		Hdr: dns.RR_Header{
+			Name:   dom,
+			Rrtype: dns.TypeAAAA,
+			Class:  dns.ClassINET,
+			Ttl:    res.ttl(rr)},
+		AAAA: rr.IP,
 	}, nil
 }
 
+// formatPTR returns the PTR resource record of rr
+func (res *Resolver) formatPTR(dom string, rr records.RR) *dns.PTR {
+	return &dns.PTR{
+		Hdr: dns.RR_Header{
+			Name:   dom,
+			Rrtype: dns.TypePTR,
+			Class:  dns.ClassINET,
+			Ttl:    res.ttl(rr),
+		},
+		Ptr: rr.Target,
+	}
+}
+
+// formatTXT returns the TXT resource record of rr
+func (res *Resolver) formatTXT(dom string, rr records.RR) *dns.TXT {
+	return &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   dom,
+			Rrtype: dns.TypeTXT,
+			Class:  dns.ClassINET,
+			Ttl:    res.ttl(rr),
+		},
+		Txt: []string{rr.Text},
+	}
+}
+
+// formatCNAME returns the CNAME resource record of rr
+func (res *Resolver) formatCNAME(dom string, rr records.RR) *dns.CNAME {
+	return &dns.CNAME{
+		Hdr: dns.RR_Header{
+			Name:   dom,
+			Rrtype: dns.TypeCNAME,
+			Class:  dns.ClassINET,
+			Ttl:    res.ttl(rr),
+		},
+		Target: rr.Target,
+	}
+}
+
+// formatMX returns the MX resource record of rr
+func (res *Resolver) formatMX(dom string, rr records.RR) *dns.MX {
+	return &dns.MX{
+		Hdr: dns.RR_Header{
+			Name:   dom,
+			Rrtype: dns.TypeMX,
+			Class:  dns.ClassINET,
+			Ttl:    res.ttl(rr),
+		},
+		Preference: rr.Priority,
+		Mx:         rr.Target,
+	}
+}
+
+// formatDelegation returns the NS resource record of rr, delegating a
+// subdomain
+func (res *Resolver) formatDelegation(rr records.RR) *dns.NS {
+	return &dns.NS{
+		Hdr: dns.RR_Header{
+			Name:   rr.Name,
+			Rrtype: dns.TypeNS,
+			Class:  dns.ClassINET,
+			Ttl:    res.ttl(rr),
+		},
+		Ns: rr.Target,
+	}
+}
+
 // formatSOA returns the SOA resource record for the mesos domain
 func (res *Resolver) formatSOA(dom string) *dns.SOA {
 	ttl := uint32(res.config.TTL)
@@ -274,7 +483,10 @@ func rcode(err error) int {
 
 // HandleMesos is a resolver request handler that responds to a resource
 // question with resource answer(s)
-// it can handle {A, SRV, ANY}
+// it can handle {A, AAAA, SRV, PTR, TXT, CNAME, MX, ANY}
+// Queries of aliases are answered with their CNAME records followed by the
+// records of their targets, and queries of delegated subdomains with
+// referrals to their name servers.
 func (res *Resolver) HandleMesos(w dns.ResponseWriter, r *dns.Msg) {
 	logging.CurLog.MesosRequests.Inc()
 
@@ -287,19 +499,56 @@ func (res *Resolver) HandleMesos(w dns.ResponseWriter, r *dns.Msg) {
 	var errs multiError
 	rs := res.records()
 	name := strings.ToLower(cleanWild(r.Question[0].Name))
+
+	if nss := res.delegation(rs, name); len(nss) > 0 {
+		res.handleReferral(rs, nss, m)
+		logging.CurLog.MesosSuccess.Inc()
+		reply(w, m)
+		return
+	}
+
+	var cnames []dns.RR
+	switch r.Question[0].Qtype {
+	case dns.TypeSRV, dns.TypeA, dns.TypeAAAA, dns.TypeTXT, dns.TypeMX:
+		target, chain := rs.Records.Follow(name)
+		for _, rr := range chain {
+			cnames = append(cnames, res.formatCNAME(rr.Name, rr))
+		}
+		if len(chain) > 0 {
+			// answer for the target as if it had been asked for
+			name, r = target, r.Copy()
+			r.Question[0].Name = target
+		}
+	}
+
 	switch r.Question[0].Qtype {
 	case dns.TypeSRV:
 		errs.Add(res.handleSRV(rs, name, m, r))
 	case dns.TypeA:
 		errs.Add(res.handleA(rs, name, m))
+	case dns.TypeAAAA:
+		errs.Add(res.handleAAAA(rs, name, m))
+	case dns.TypePTR:
+		errs.Add(res.handlePTR(rs, name, m))
+	case dns.TypeTXT:
+		errs.Add(res.handleTXT(rs, name, m))
 	case dns.TypeSOA:
 		errs.Add(res.handleSOA(m, r))
 	case dns.TypeNS:
 		errs.Add(res.handleNS(m, r))
+	case dns.TypeCNAME:
+		errs.Add(res.handleCNAME(rs, name, m))
+	case dns.TypeMX:
+		errs.Add(res.handleMX(rs, name, m))
 	case dns.TypeANY:
 		errs.Add(
 			res.handleSRV(rs, name, m, r),
 			res.handleA(rs, name, m),
+			res.handleAAAA(rs, name, m),
+			res.handlePTR(rs, name, m),
+			res.handleTXT(rs, name, m),
+			res.handleCNAME(rs, name, m),
+			res.handleMX(rs, name, m),
 			res.handleSOA(m, r),
 			res.handleNS(m, r),
 		)
@@ -311,6 +560,7 @@ func (res *Resolver) HandleMesos(w dns.ResponseWriter, r *dns.Msg) {
 		shuffleAnswers(res.rng, m.Answer)
 		logging.CurLog.MesosSuccess.Inc()
 	}
+	m.Answer = append(cnames, m.Answer...)
 
 	if !errs.Nil() {
 		logging.Error.Println(errs.Error())
@@ -322,34 +572,47 @@ func (res *Resolver) HandleMesos(w dns.ResponseWriter, r *dns.Msg) {
 
 func (res *Resolver) handleSRV(rs *records.RecordGenerator, name string, m, r *dns.Msg) error {
 	var errs multiError
-	for _, srv := range rs.SRVs[name] {
-		srvRR, err := res.formatSRV(r.Question[0].Name, srv)
-		if err != nil {
-			errs.Add(err)
-			continue
-		}
-
+	for _, srv := range rs.Records.Get(name, dns.TypeSRV) {
+		srvRR := res.formatSRV(r.Question[0].Name, srv)
 		m.Answer = append(m.Answer, srvRR)
-		host := strings.Split(srv, ":")[0]
-		if len(rs.As[host]) == 0 {
-			continue
+		host := srvRR.Target
+		if as := rs.Records.Get(host, dns.TypeA); len(as) > 0 {
+			aRR, err := res.formatA(host, as[0])
+			if err != nil {
+				errs.Add(err)
+			} else {
+				m.Extra = append(m.Extra, aRR)
+			}
+		}
+		if aaaas := rs.Records.Get(host, dns.TypeAAAA); len(aaaas) > 0 {
+			aaaaRR, err := res.formatAAAA(host, aaaas[0])
+			if err != nil {
+				errs.Add(err)
+			} else {
+				m.Extra = append(m.Extra, aaaaRR)
+			}
 		}
+	}
+	return errs
+}
 
-		aRR, err := res.formatA(host, rs.As[host][0])
+func (res *Resolver) handleA(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+	var errs multiError
+	for _, a := range rs.Records.Get(name, dns.TypeA) {
+		rr, err := res.formatA(name, a)
 		if err != nil {
 			errs.Add(err)
 			continue
 		}
-
-		m.Extra = append(m.Extra, aRR)
+		m.Answer = append(m.Answer, rr)
 	}
 	return errs
 }
 
-func (res *Resolver) handleA(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+func (res *Resolver) handleAAAA(rs *records.RecordGenerator, name string, m *dns.Msg) error {
 	var errs multiError
-	for _, a := range rs.As[name] {
-		rr, err := res.formatA(name, a)
+	for _, aaaa := range rs.Records.Get(name, dns.TypeAAAA) {
+		rr, err := res.formatAAAA(name, aaaa)
 		if err != nil {
 			errs.Add(err)
 			continue
@@ -359,6 +622,65 @@ func (res *Resolver) handleA(rs *records.RecordGenerator, name string, m *dns.Ms
 	return errs
 }
 
+func (res *Resolver) handlePTR(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+	for _, ptr := range rs.Records.Get(name, dns.TypePTR) {
+		m.Answer = append(m.Answer, res.formatPTR(name, ptr))
+	}
+	return nil
+}
+
+func (res *Resolver) handleTXT(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+	for _, txt := range rs.Records.Get(name, dns.TypeTXT) {
+		m.Answer = append(m.Answer, res.formatTXT(name, txt))
+	}
+	return nil
+}
+
+func (res *Resolver) handleCNAME(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+	for _, cname := range rs.Records.Get(name, dns.TypeCNAME) {
+		m.Answer = append(m.Answer, res.formatCNAME(name, cname))
+	}
+	return nil
+}
+
+func (res *Resolver) handleMX(rs *records.RecordGenerator, name string, m *dns.Msg) error {
+	for _, mx := range rs.Records.Get(name, dns.TypeMX) {
+		m.Answer = append(m.Answer, res.formatMX(name, mx))
+	}
+	return nil
+}
+
+// delegation returns the NS records of the subdomain of the Mesos domain the
+// given name is delegated to, if any.
+func (res *Resolver) delegation(rs *records.RecordGenerator, name string) []records.RR {
+	zone := "." + res.config.Domain + "."
+	for n := name; strings.HasSuffix(n, zone); n = n[strings.Index(n, ".")+1:] {
+		if nss := rs.Records.Get(n, dns.TypeNS); len(nss) > 0 {
+			return nss
+		}
+	}
+	return nil
+}
+
+// handleReferral refers the query to the name servers of a delegated
+// subdomain, along with the addresses of the name servers we know of.
+func (res *Resolver) handleReferral(rs *records.RecordGenerator, nss []records.RR, m *dns.Msg) {
+	m.Authoritative = false
+	for _, ns := range nss {
+		m.Ns = append(m.Ns, res.formatDelegation(ns))
+		for _, a := range rs.Records.Get(ns.Target, dns.TypeA) {
+			if rr, err := res.formatA(ns.Target, a); err == nil {
+				m.Extra = append(m.Extra, rr)
+			}
+		}
+		for _, aaaa := range rs.Records.Get(ns.Target, dns.TypeAAAA) {
+			if rr, err := res.formatAAAA(ns.Target, aaaa); err == nil {
+				m.Extra = append(m.Extra, rr)
+			}
+		}
+	}
+}
+
 func (res *Resolver) handleSOA(m, r *dns.Msg) error {
 	m.Ns = append(m.Ns, res.formatSOA(r.Question[0].Name))
 	return nil
@@ -379,26 +701,14 @@ func (res *Resolver) handleEmpty(rs *records.RecordGenerator, name string, m, r
 
 	m.Rcode = dns.RcodeNameError
 
-	// Because we don't implement AAAA records, AAAA queries will always
-	// go via this path
-	// Unfortunately, we don't implement AAAA queries in Mesos-DNS,
-	// and although the 'Not Implemented' error code seems more suitable,
-	// RFCs do not recommend it: https://tools.ietf.org/html/rfc4074
-	// Therefore we always return success, which is synonymous with NODATA
-	// to get a positive cache on no records AAAA
-	// Further information:
-	// PR: https://github.com/mesosphere/mesos-dns/pull/366
-	// Issue: https://github.com/mesosphere/mesos-dns/issues/363
-
-	// The second component is just a matter of returning NODATA if we have
-	// SRV or A records for the given name, but no neccessarily the given query
-
-	if (qType == dns.TypeAAAA) || (len(rs.SRVs[name])+len(rs.As[name]) > 0) {
+	// Return NODATA if we have SRV, A, AAAA, PTR or TXT records for the given
+	// name, but not necessarily for the given query type.
+	if rs.Records.Has(name) {
 		m.Rcode = dns.RcodeSuccess
 	}
 
 	logging.CurLog.MesosNXDomain.Inc()
-	logging.VeryVerbose.Println("total A rrs:\t" + strconv.Itoa(len(rs.As)))
+	logging.VeryVerbose.Println("total A rrs:\t" + strconv.Itoa(rs.Records.Count(dns.TypeA)))
 	logging.VeryVerbose.Println("failed looking for " + r.Question[0].String())
 
 	m.Ns = append(m.Ns, res.formatSOA(r.Question[0].Name))
@@ -466,6 +776,8 @@ func (res *Resolver) configureHTTP() {
 	ws.Route(ws.GET("/v1/hosts/{host}").To(res.RestHost))
 	ws.Route(ws.GET("/v1/hosts/{host}/ports").To(res.RestPorts))
 	ws.Route(ws.GET("/v1/services/{service}").To(res.RestService))
+	ws.Route(ws.GET("/v1/filtered").To(res.RestFiltered))
+	ws.Route(ws.GET("/v1/collisions").To(res.RestCollisions))
 	restful.Add(ws)
 }
 
@@ -510,7 +822,7 @@ func (res *Resolver) RestVersion(req *restful.Request, resp *restful.Response) {
 	}
 }
 
-// RestHost handles HTTP requests of DNS A records of the given host.
+// RestHost handles HTTP requests of DNS A and AAAA records of the given host.
 func (res *Resolver) RestHost(req *restful.Request, resp *restful.Response) {
 	host := req.PathParameter("host")
 	// clean up host name
@@ -523,12 +835,15 @@ func (res *Resolver) RestHost(req *restful.Request, resp *restful.Response) {
 	type record struct {
 		Host string `json:"host"`
 		IP   string `json:"ip"`
+		TTL  uint32 `json:"ttl,omitempty"`
 	}
 
-	aRRs := rs.As[dom]
+	// aliases list the addresses of their targets
+	target, _ := rs.Records.Follow(dom)
+	aRRs := append(append([]records.RR{}, rs.Records.Get(target, dns.TypeA)...), rs.Records.Get(target, dns.TypeAAAA)...)
 	records := make([]record, 0, len(aRRs))
-	for _, ip := range aRRs {
-		records = append(records, record{dom, ip})
+	for _, rr := range aRRs {
+		records = append(records, record{dom, rr.IP.String(), res.ttl(rr)})
 	}
 
 	if len(records) == 0 {
@@ -579,17 +894,22 @@ func (res *Resolver) RestService(req *restful.Request, resp *restful.Response) {
 		Host    string `json:"host"`
 		IP      string `json:"ip"`
 		Port    string `json:"port"`
+		TTL     uint32 `json:"ttl,omitempty"`
 	}
 
-	srvRRs := rs.SRVs[dom]
+	// aliases list the services of their targets
+	target, _ := rs.Records.Follow(dom)
+	srvRRs := rs.Records.Get(target, dns.TypeSRV)
 	records := make([]record, 0, len(srvRRs))
 	for _, s := range srvRRs {
-		host, port, _ := net.SplitHostPort(s)
+		// targets without IPv4 addresses fall back to IPv6 ones
 		var ip string
-		if r := rs.As[host]; len(r) != 0 {
-			ip = r[0]
+		if r := rs.Records.Get(s.Target, dns.TypeA); len(r) != 0 {
+			ip = r[0].IP.String()
+		} else if r := rs.Records.Get(s.Target, dns.TypeAAAA); len(r) != 0 {
+			ip = r[0].IP.String()
 		}
-		records = append(records, record{service, host, ip, port})
+		records = append(records, record{service, s.Target, ip, strconv.Itoa(int(s.Port)), res.ttl(s)})
 	}
 
 	if len(records) == 0 {
@@ -603,6 +923,30 @@ func (res *Resolver) RestService(req *restful.Request, resp *restful.Response) {
 	stats(dom, res.config.Domain+".", len(srvRRs) > 0)
 }
 
+// RestFiltered handles HTTP requests of the number of A records dropped by
+// IP filters in the current record set, per record class.
+func (res *Resolver) RestFiltered(req *restful.Request, resp *restful.Response) {
+	filtered := res.records().Filtered
+	if filtered == nil {
+		filtered = map[string]int{}
+	}
+	if err := resp.WriteAsJson(filtered); err != nil {
+		logging.Error.Println(err)
+	}
+}
+
+// RestCollisions handles HTTP requests of the names claimed by several apps
+// in the current record set.
+func (res *Resolver) RestCollisions(req *restful.Request, resp *restful.Response) {
+	collisions := res.records().Collisions
+	if collisions == nil {
+		collisions = []records.Collision{}
+	}
+	if err := resp.WriteAsJson(collisions); err != nil {
+		logging.Error.Println(err)
+	}
+}
+
 // panicRecover catches any panics from the resolvers and sets an error
 // code of server failure
 func panicRecover(f func(w dns.ResponseWriter, r *dns.Msg)) func(w dns.ResponseWriter, r *dns.Msg) {
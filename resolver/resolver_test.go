@@ -13,6 +13,7 @@ import (
 	"reflect"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/kylelemons/godebug/pretty"
 	. "github.com/mesosphere/mesos-dns/dnstest"
@@ -45,8 +46,8 @@ func TestShuffleAnswers(t *testing.T) {
 	m := new(dns.Msg)
 
 	for i := 0; i < 10; i++ {
-		name := "10.0.0." + strconv.Itoa(i)
-		rr, err := res.formatA("blah.com", name)
+		ip := net.ParseIP("10.0.0." + strconv.Itoa(i))
+		rr, err := res.formatA("blah.com", records.RR{Type: dns.TypeA, IP: ip})
 		if err != nil {
 			t.Error(err)
 		}
@@ -94,12 +95,14 @@ func runHandlers() error {
 	if err != nil {
 		return err
 	}
+	google1 := records.RR{Type: dns.TypeA, IP: net.ParseIP("1.1.1.1")}
+	google2 := records.RR{Type: dns.TypeA, IP: net.ParseIP("2.2.2.2")}
 	res.fwd = func(m *dns.Msg, net string) (*dns.Msg, error) {
-		rr1, err := res.formatA("google.com.", "1.1.1.1")
+		rr1, err := res.formatA("google.com.", google1)
 		if err != nil {
 			return nil, err
 		}
-		rr2, err := res.formatA("google.com.", "2.2.2.2")
+		rr2, err := res.formatA("google.com.", google2)
 		if err != nil {
 			return nil, err
 		}
@@ -218,7 +221,7 @@ func runHandlers() error {
 			res.HandleMesos,
 			Message(
 				Question("missing.mesos.", dns.TypeAAAA),
-				Header(true, dns.RcodeSuccess),
+				Header(true, dns.RcodeNameError),
 				NSs(
 					SOA(RRHeader("missing.mesos.", dns.TypeSOA, 60),
 						"ns1.mesos", "root.ns1.mesos", 60))),
@@ -242,6 +245,222 @@ func runHandlers() error {
 	return nil
 }
 
+func TestHandleMesos_AAAA(t *testing.T) {
+	res, err := fakeDNS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.rs.Records = records.NewRecordSet(append(res.rs.Records.All(),
+		records.RR{Name: "web.marathon.mesos.", Type: dns.TypeAAAA, IP: net.ParseIP("fd00::1")},
+		records.RR{Name: "web.marathon.mesos.", Type: dns.TypeA, IP: net.ParseIP("10.0.0.1")},
+		records.RR{Name: "_web._tcp.marathon.mesos.", Type: dns.TypeSRV, Target: "web.marathon.mesos.", Port: 80},
+	)...)
+
+	for i, tt := range []*dns.Msg{
+		Message(
+			Question("web.marathon.mesos.", dns.TypeAAAA),
+			Header(true, dns.RcodeSuccess),
+			Answers(
+				AAAA(RRHeader("web.marathon.mesos.", dns.TypeAAAA, 60),
+					net.ParseIP("fd00::1")))),
+		Message(
+			Question("_web._tcp.marathon.mesos.", dns.TypeSRV),
+			Header(true, dns.RcodeSuccess),
+			Answers(
+				SRV(RRHeader("_web._tcp.marathon.mesos.", dns.TypeSRV, 60),
+					"web.marathon.mesos.", 80, 0, 0)),
+			Extras(
+				A(RRHeader("web.marathon.mesos.", dns.TypeA, 60),
+					net.ParseIP("10.0.0.1")),
+				AAAA(RRHeader("web.marathon.mesos.", dns.TypeAAAA, 60),
+					net.ParseIP("fd00::1")))),
+	} {
+		var rw ResponseRecorder
+		res.HandleMesos(&rw, tt)
+		if got, want := rw.Msg, tt; !reflect.DeepEqual(got, want) {
+			t.Errorf("Test #%d\n%v\n%s\n", i, pretty.Sprint(tt.Question), pretty.Compare(got, want))
+		}
+	}
+}
+
+func TestHandleMesos_CNAME(t *testing.T) {
+	res, err := fakeDNS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.rs.Records = records.NewRecordSet(append(res.rs.Records.All(),
+		records.RR{Name: "web.marathon.mesos.", Type: dns.TypeA, IP: net.ParseIP("10.0.0.1")},
+		records.RR{Name: "_web._tcp.marathon.mesos.", Type: dns.TypeSRV, Target: "web.marathon.mesos.", Port: 80},
+		records.RR{Name: "api.mesos.", Type: dns.TypeCNAME, Target: "web.marathon.mesos."},
+		records.RR{Name: "_api._tcp.mesos.", Type: dns.TypeCNAME, Target: "_web._tcp.marathon.mesos."},
+	)...)
+
+	for i, tt := range []*dns.Msg{
+		Message(
+			Question("api.mesos.", dns.TypeA),
+			Header(true, dns.RcodeSuccess),
+			Answers(
+				CNAME(RRHeader("api.mesos.", dns.TypeCNAME, 60),
+					"web.marathon.mesos."),
+				A(RRHeader("web.marathon.mesos.", dns.TypeA, 60),
+					net.ParseIP("10.0.0.1")))),
+		Message(
+			Question("_api._tcp.mesos.", dns.TypeSRV),
+			Header(true, dns.RcodeSuccess),
+			Answers(
+				CNAME(RRHeader("_api._tcp.mesos.", dns.TypeCNAME, 60),
+					"_web._tcp.marathon.mesos."),
+				SRV(RRHeader("_web._tcp.marathon.mesos.", dns.TypeSRV, 60),
+					"web.marathon.mesos.", 80, 0, 0)),
+			Extras(
+				A(RRHeader("web.marathon.mesos.", dns.TypeA, 60),
+					net.ParseIP("10.0.0.1")))),
+		Message(
+			Question("api.mesos.", dns.TypeCNAME),
+			Header(true, dns.RcodeSuccess),
+			Answers(
+				CNAME(RRHeader("api.mesos.", dns.TypeCNAME, 60),
+					"web.marathon.mesos."))),
+		Message(
+			Question("api.mesos.", dns.TypeAAAA),
+			Header(true, dns.RcodeSuccess),
+			Answers(
+				CNAME(RRHeader("api.mesos.", dns.TypeCNAME, 60),
+					"web.marathon.mesos.")),
+			NSs(
+				SOA(RRHeader("web.marathon.mesos.", dns.TypeSOA, 60),
+					"ns1.mesos", "root.ns1.mesos", 60))),
+	} {
+		var rw ResponseRecorder
+		res.HandleMesos(&rw, tt)
+		if got, want := rw.Msg, tt; !reflect.DeepEqual(got, want) {
+			t.Errorf("Test #%d\n%v\n%s\n", i, pretty.Sprint(tt.Question), pretty.Compare(got, want))
+		}
+	}
+}
+
+func TestHandleMesos_Static(t *testing.T) {
+	res, err := fakeDNS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.rs.Records = records.NewRecordSet(append(res.rs.Records.All(),
+		records.RR{Name: "mail.mesos.", Type: dns.TypeMX, Priority: 10, Target: "mx.mesos."},
+		records.RR{Name: "sub.mesos.", Type: dns.TypeNS, Target: "ns.sub.mesos.", TTL: 300},
+		records.RR{Name: "ns.sub.mesos.", Type: dns.TypeA, IP: net.ParseIP("10.0.0.53")},
+	)...)
+
+	for i, tt := range []*dns.Msg{
+		Message(
+			Question("mail.mesos.", dns.TypeMX),
+			Header(true, dns.RcodeSuccess),
+			Answers(
+				&dns.MX{Hdr: RRHeader("mail.mesos.", dns.TypeMX, 60), Preference: 10, Mx: "mx.mesos."})),
+		Message(
+			Question("db.sub.mesos.", dns.TypeA),
+			Header(false, dns.RcodeSuccess),
+			NSs(
+				NS(RRHeader("sub.mesos.", dns.TypeNS, 300), "ns.sub.mesos.")),
+			Extras(
+				A(RRHeader("ns.sub.mesos.", dns.TypeA, 60),
+					net.ParseIP("10.0.0.53")))),
+	} {
+		var rw ResponseRecorder
+		res.HandleMesos(&rw, tt)
+		if got, want := rw.Msg, tt; !reflect.DeepEqual(got, want) {
+			t.Errorf("Test #%d\n%v\n%s\n", i, pretty.Sprint(tt.Question), pretty.Compare(got, want))
+		}
+	}
+}
+
+func TestHandleMesos_SRVOptions(t *testing.T) {
+	res, err := fakeDNS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rrs []records.RR
+	for _, rr := range res.rs.Records.All() {
+		if rr.Name == "_leader._tcp.mesos." && rr.Type == dns.TypeSRV {
+			rr.Priority, rr.Weight = 1, 10
+		}
+		rrs = append(rrs, rr)
+	}
+	res.rs.Records = records.NewRecordSet(rrs...)
+
+	want := Message(
+		Question("_leader._tcp.mesos.", dns.TypeSRV),
+		Header(true, dns.RcodeSuccess),
+		Answers(
+			SRV(RRHeader("_leader._tcp.mesos.", dns.TypeSRV, 60),
+				"leader.mesos.", 5050, 1, 10)),
+		Extras(
+			A(RRHeader("leader.mesos.", dns.TypeA, 60),
+				net.ParseIP("1.2.3.4"))))
+
+	var rw ResponseRecorder
+	res.HandleMesos(&rw, want)
+	if got := rw.Msg; !reflect.DeepEqual(got, want) {
+		t.Error(pretty.Compare(got, want))
+	}
+}
+
+func TestHandleMesos_TTL(t *testing.T) {
+	res, err := fakeDNS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.rs.Records = records.NewRecordSet(append(res.rs.Records.All(),
+		records.RR{Name: "web.marathon.mesos.", Type: dns.TypeA, IP: net.ParseIP("10.0.0.1"), TTL: 5},
+	)...)
+
+	want := Message(
+		Question("web.marathon.mesos.", dns.TypeA),
+		Header(true, dns.RcodeSuccess),
+		Answers(
+			A(RRHeader("web.marathon.mesos.", dns.TypeA, 5),
+				net.ParseIP("10.0.0.1"))))
+
+	var rw ResponseRecorder
+	res.HandleMesos(&rw, want)
+	if got := rw.Msg; !reflect.DeepEqual(got, want) {
+		t.Error(pretty.Compare(got, want))
+	}
+}
+
+func TestHandleMesos_PTR(t *testing.T) {
+	res, err := fakeDNS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range []*dns.Msg{
+		Message(
+			Question("1.0.3.10.in-addr.arpa.", dns.TypePTR),
+			Header(true, dns.RcodeSuccess),
+			Answers(
+				PTR(RRHeader("1.0.3.10.in-addr.arpa.", dns.TypePTR, 60),
+					"liquor-store-4dfjd-0.marathon.mesos."))),
+		Message(
+			Question("37.157.76.144.in-addr.arpa.", dns.TypePTR),
+			Header(true, dns.RcodeSuccess),
+			Answers(
+				PTR(RRHeader("37.157.76.144.in-addr.arpa.", dns.TypePTR, 60),
+					"master0.mesos."))),
+		Message(
+			Question("99.0.3.10.in-addr.arpa.", dns.TypePTR),
+			Header(true, dns.RcodeNameError),
+			NSs(
+				SOA(RRHeader("99.0.3.10.in-addr.arpa.", dns.TypeSOA, 60),
+					"ns1.mesos", "root.ns1.mesos", 60))),
+	} {
+		var rw ResponseRecorder
+		res.HandleMesos(&rw, tt)
+		if got, want := rw.Msg, tt; !reflect.DeepEqual(got, want) {
+			t.Errorf("Test #%d\n%v\n%s\n", i, pretty.Sprint(tt.Question), pretty.Compare(got, want))
+		}
+	}
+}
+
 func TestHTTP(t *testing.T) {
 	// setup DNS server (just http)
 	res, err := fakeDNS()
@@ -249,6 +468,15 @@ func TestHTTP(t *testing.T) {
 		t.Fatal(err)
 	}
 	res.version = "0.1.1"
+	res.rs.Collisions = []records.Collision{{
+		Name: "web.mesos.",
+		Apps: []records.App{{Framework: "marathon", Task: "web"}, {Framework: "aurora", Task: "web"}},
+	}}
+
+	res.rs.Records = res.rs.Records.With(
+		records.RR{Name: "_db._tcp.mesos.", Type: dns.TypeSRV, Target: "db.mesos.", Port: 5432},
+		records.RR{Name: "db.mesos.", Type: dns.TypeAAAA, IP: net.ParseIP("fd00::1")},
+	)
 
 	res.configureHTTP()
 	srv := httptest.NewServer(http.DefaultServeMux)
@@ -273,6 +501,34 @@ func TestHTTP(t *testing.T) {
 				"host":    "leader.mesos.",
 				"ip":      "1.2.3.4",
 				"port":    "5050",
+				"ttl":     60.0,
+			}},
+		},
+		{"/v1/services/_https._liquor-store._tcp.marathon.mesos.", http.StatusOK, []interface{}{},
+			[]interface{}{
+				map[string]interface{}{
+					"service": "_https._liquor-store._tcp.marathon.mesos.",
+					"host":    "liquor-store-4dfjd-0.marathon.mesos.",
+					"ip":      "10.3.0.1",
+					"port":    "443",
+					"ttl":     60.0,
+				},
+				map[string]interface{}{
+					"service": "_https._liquor-store._tcp.marathon.mesos.",
+					"host":    "liquor-store-zasmd-1.marathon.mesos.",
+					"ip":      "10.3.0.2",
+					"port":    "443",
+					"ttl":     60.0,
+				},
+			},
+		},
+		{"/v1/services/_db._tcp.mesos.", http.StatusOK, []interface{}{},
+			[]interface{}{map[string]interface{}{
+				"service": "_db._tcp.mesos.",
+				"host":    "db.mesos.",
+				"ip":      "fd00::1",
+				"port":    "5432",
+				"ttl":     60.0,
 			}},
 		},
 		{"/v1/services/_myservice._tcp.mesos.", http.StatusOK, []interface{}{},
@@ -283,10 +539,29 @@ func TestHTTP(t *testing.T) {
 				"port":    "",
 			}},
 		},
+		{"/v1/filtered", http.StatusOK, map[string]interface{}{},
+			map[string]interface{}{
+				"task":      0.0,
+				"agent":     0.0,
+				"master":    0.0,
+				"framework": 0.0,
+				"listener":  0.0,
+			},
+		},
+		{"/v1/collisions", http.StatusOK, []interface{}{},
+			[]interface{}{map[string]interface{}{
+				"name": "web.mesos.",
+				"apps": []interface{}{
+					map[string]interface{}{"framework": "marathon", "task": "web"},
+					map[string]interface{}{"framework": "aurora", "task": "web"},
+				},
+			}},
+		},
 		{"/v1/hosts/leader.mesos", http.StatusOK, []interface{}{},
 			[]interface{}{map[string]interface{}{
 				"host": "leader.mesos.",
 				"ip":   "1.2.3.4",
+				"ttl":  60.0,
 			}},
 		},
 	} {
@@ -304,6 +579,134 @@ func TestHTTP(t *testing.T) {
 	}
 }
 
+func TestResolver_Subscribe(t *testing.T) {
+	res, err := fakeDNS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, cancel := res.Subscribe(1)
+
+	rr := records.RR{Name: "web.marathon.mesos.", Type: dns.TypeA, IP: net.ParseIP("10.0.0.1").To4()}
+	next := records.NewRecordGenerator(0)
+	next.Records = records.NewRecordSet(append(res.rs.Records.All(), rr)...)
+	res.swap(next)
+
+	select {
+	case d := <-changes:
+		want := records.Diff{Added: []records.RR{rr}, AddedNames: []string{rr.Name}}
+		if !reflect.DeepEqual(d, want) {
+			t.Errorf("got %+v, want %+v", d, want)
+		}
+	default:
+		t.Fatal("no changes published")
+	}
+	if res.records() != next {
+		t.Error("record set wasn't swapped")
+	}
+
+	// unchanged records aren't published
+	res.swap(next)
+	select {
+	case d := <-changes:
+		t.Errorf("unexpected changes: %+v", d)
+	default:
+	}
+
+	cancel()
+	if _, ok := <-changes; ok {
+		t.Error("channel not closed after cancel")
+	}
+}
+
+func TestDrainer(t *testing.T) {
+	task := func(id, ip string) records.RR {
+		return records.RR{
+			Name:   "web.marathon.mesos.",
+			Type:   dns.TypeA,
+			IP:     net.ParseIP(ip).To4(),
+			Class:  records.TaskClass,
+			Origin: records.Origin{TaskID: id},
+		}
+	}
+	web1, web2 := task("web.1", "10.0.0.1"), task("web.2", "10.0.0.2")
+	drained := web2
+	drained.TTL = 5
+
+	d := drainer{grace: 30 * time.Second, ttl: 5}
+	start := time.Unix(0, 0)
+	prev := records.NewRecordSet(web1, web2)
+	for i, tt := range []struct {
+		next *records.RecordSet
+		at   time.Duration
+		want []records.RR
+	}{
+		{records.NewRecordSet(web1, web2), 0, []records.RR{web1, web2}},
+		{records.NewRecordSet(web1), 10 * time.Second, []records.RR{web1, drained}},
+		{records.NewRecordSet(web1), 20 * time.Second, []records.RR{web1, drained}},
+		{records.NewRecordSet(web1), 40 * time.Second, []records.RR{web1}},
+		{records.NewRecordSet(web1), 50 * time.Second, []records.RR{web1}},
+	} {
+		got := d.drain(prev, tt.next, nil, start.Add(tt.at))
+		if rrs := got.Get("web.marathon.mesos.", dns.TypeA); !reflect.DeepEqual(rrs, tt.want) {
+			t.Errorf("test #%d: got %v, want %v", i, rrs, tt.want)
+		}
+		prev = got
+	}
+
+	// without a grace period, nothing is kept
+	d = drainer{}
+	if got := d.drain(records.NewRecordSet(web1, web2), records.NewRecordSet(web1), nil, start); got.Len() != 1 {
+		t.Errorf("got %d records, want 1", got.Len())
+	}
+
+	// live tasks aren't drained
+	d = drainer{grace: 30 * time.Second}
+	live := map[string]struct{}{"web.2": {}}
+	if got := d.drain(records.NewRecordSet(web1, web2), records.NewRecordSet(web1), live, start); got.Len() != 1 {
+		t.Errorf("live task: got %d records, want 1", got.Len())
+	}
+}
+
+func TestSwap_Drain(t *testing.T) {
+	config := records.NewConfig()
+	config.DrainSeconds = 30
+	config.HealthPolicy = records.HealthyPolicy
+	res := New("", config)
+
+	const sjJSON = `{
+		"leader": "master@1.2.3.1:5050",
+		"slaves": [{"id": "s-0", "pid": "slave(1)@1.2.3.4:5051"}],
+		"frameworks": [{"id": "fw0", "name": "marathon", "tasks": [
+			{"id": "web.1", "name": "web", "slave_id": "s-0", "state": %q,
+			 "statuses": [{"state": "TASK_RUNNING", "healthy": %t,
+			  "container_status": {"network_infos": [{"ip_address": "10.0.0.1"}]}}]}
+		]}]
+	}`
+	for i, tt := range []struct {
+		state   string
+		healthy bool
+		want    int
+	}{
+		{"TASK_RUNNING", true, 1},
+		{"TASK_RUNNING", false, 0}, // unhealthy tasks still running aren't drained
+		{"TASK_RUNNING", true, 1},
+		{"TASK_KILLED", true, 1}, // stopped tasks are
+	} {
+		var sj state.State
+		if err := json.Unmarshal([]byte(fmt.Sprintf(sjJSON, tt.state, tt.healthy)), &sj); err != nil {
+			t.Fatal(err)
+		}
+		rg := records.NewRecordGenerator(0, records.WithConfig(config))
+		if err := rg.InsertState(sj, "mesos", "mesos-dns.mesos.", "127.0.0.1", nil, []string{"netinfo"}, labels.RFC1123); err != nil {
+			t.Fatal(err)
+		}
+		res.swap(rg)
+		if got := len(res.records().Records.Get("web.marathon.mesos.", dns.TypeA)); got != tt.want {
+			t.Errorf("test #%d: got %d records, want %d", i, got, tt.want)
+		}
+	}
+}
+
 func fakeDNS() (*Resolver, error) {
 	config := records.NewConfig()
 	config.Masters = []string{"144.76.157.37:5050"}
@@ -408,3 +811,29 @@ func genA(n int) []dns.RR {
 	}
 	return records
 }
+
+func BenchmarkHandleMesos_A(b *testing.B) {
+	benchmarkHandleMesos(b, "liquor-store.marathon.mesos.", dns.TypeA)
+}
+
+func BenchmarkHandleMesos_SRV(b *testing.B) {
+	benchmarkHandleMesos(b, "_liquor-store._tcp.marathon.mesos.", dns.TypeSRV)
+}
+
+func BenchmarkHandleMesos_ANY(b *testing.B) {
+	benchmarkHandleMesos(b, "leader.mesos.", dns.TypeANY)
+}
+
+func benchmarkHandleMesos(b *testing.B, qname string, qtype uint16) {
+	res, err := fakeDNS()
+	if err != nil {
+		b.Fatal(err)
+	}
+	q := Message(Question(qname, qtype))
+	b.ReportAllocs()
+	b.ResetTimer()
+	var rw ResponseRecorder
+	for n := 0; n < b.N; n++ {
+		res.HandleMesos(&rw, q)
+	}
+}
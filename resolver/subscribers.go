@@ -0,0 +1,51 @@
+package resolver
+
+import (
+	"sync"
+
+	"github.com/mesosphere/mesos-dns/logging"
+	"github.com/mesosphere/mesos-dns/records"
+)
+
+// subscribers holds the channels record changes are published on.
+// It's safe for concurrent use.
+type subscribers struct {
+	sync.Mutex
+	chs map[chan records.Diff]struct{}
+}
+
+// subscribe registers a new channel with the given buffer size, returning it
+// along with a function which unregisters and closes it.
+func (s *subscribers) subscribe(buffer int) (<-chan records.Diff, func()) {
+	ch := make(chan records.Diff, buffer)
+	s.Lock()
+	defer s.Unlock()
+	if s.chs == nil {
+		s.chs = map[chan records.Diff]struct{}{}
+	}
+	s.chs[ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.Lock()
+			defer s.Unlock()
+			delete(s.chs, ch)
+			close(ch)
+		})
+	}
+}
+
+// publish sends the given Diff to every subscriber without blocking,
+// dropping it for subscribers which aren't keeping up.
+func (s *subscribers) publish(d records.Diff) {
+	s.Lock()
+	defer s.Unlock()
+	for ch := range s.chs {
+		select {
+		case ch <- d:
+		default:
+			logging.Error.Println("dropped record changes for slow subscriber")
+		}
+	}
+}